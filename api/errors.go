@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/kubeclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"strings"
+)
+
+// ErrorCause is a single problem of a request, usually bound to a field.
+type ErrorCause struct {
+	Type    string `json:"type,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the body of every failed REST call.
+type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Reason  string       `json:"reason,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Causes  []ErrorCause `json:"causes,omitempty"`
+}
+
+// NewBadRequest reports malformed client input.
+func NewBadRequest(message string) error {
+	return apierrors.NewBadRequest(message)
+}
+
+// NewNotFound reports a missing entity which isn't a Kubernetes object.
+func NewNotFound(message string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusNotFound,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: message,
+	}}
+}
+
+// NewInvalid reports a request body failing validation, the causes tell
+// which fields and why.
+func NewInvalid(message string, causes []ErrorCause) error {
+	details := &metav1.StatusDetails{}
+	for _, cause := range causes {
+		details.Causes = append(details.Causes, metav1.StatusCause{
+			Type:    metav1.CauseType(cause.Type),
+			Field:   cause.Field,
+			Message: cause.Message,
+		})
+	}
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusUnprocessableEntity,
+		Reason:  metav1.StatusReasonInvalid,
+		Message: message,
+		Details: details,
+	}}
+}
+
+// NewUnsupportedMediaType reports a request body of an unexpected content type.
+func NewUnsupportedMediaType(message string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusUnsupportedMediaType,
+		Reason:  metav1.StatusReasonUnsupportedMediaType,
+		Message: message,
+	}}
+}
+
+// AbortWithError aborts the request with the HTTP status matching err. Server
+// side failures are logged as errors, client side ones as warnings.
+func AbortWithError(c *gin.Context, logger *logrus.Entry, err error, msg string) {
+	code, response := ErrorResponseFor(err)
+	if code >= http.StatusInternalServerError {
+		logger.WithError(err).Error(msg)
+	} else {
+		logger.WithError(err).Warn(msg)
+	}
+	c.AbortWithStatusJSON(code, response)
+}
+
+// ErrorResponseFor translates err into an HTTP status and a response body,
+// preserving the semantics of Kubernetes API errors.
+func ErrorResponseFor(err error) (int, ErrorResponse) {
+	if errors.Is(err, kubeclient.ErrUnknownContext) {
+		return http.StatusNotFound, ErrorResponse{
+			Error:   "unknown context",
+			Reason:  string(metav1.StatusReasonNotFound),
+			Message: err.Error(),
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, ErrorResponse{
+			Error:   statusText(http.StatusGatewayTimeout),
+			Reason:  string(metav1.StatusReasonTimeout),
+			Message: err.Error(),
+		}
+	}
+
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		return http.StatusInternalServerError, ErrorResponse{
+			Error: statusText(http.StatusInternalServerError),
+		}
+	}
+	status := apiStatus.Status()
+	code := int(status.Code)
+	if code < http.StatusBadRequest {
+		code = http.StatusInternalServerError
+	}
+	response := ErrorResponse{
+		Error:   statusText(code),
+		Reason:  string(status.Reason),
+		Message: status.Message,
+	}
+	if status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			response.Causes = append(response.Causes, ErrorCause{
+				Type:    string(cause.Type),
+				Field:   cause.Field,
+				Message: cause.Message,
+			})
+		}
+	}
+	return code, response
+}
+
+func statusText(code int) string {
+	return strings.ToLower(http.StatusText(code))
+}
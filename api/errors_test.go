@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/api"
+	"k8s-explore/kubeclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
+	"testing"
+)
+
+func TestErrorResponseFor_Conflict(t *testing.T) {
+	err := apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "web", errors.New("the object has been modified"))
+
+	code, response := api.ErrorResponseFor(err)
+
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "conflict", response.Error)
+	assert.Equal(t, "Conflict", response.Reason)
+	assert.Contains(t, response.Message, "the object has been modified")
+}
+
+func TestErrorResponseFor_InvalidWithCauses(t *testing.T) {
+	err := apierrors.NewInvalid(
+		schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		"web",
+		field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0")},
+	)
+
+	code, response := api.ErrorResponseFor(fmt.Errorf("update failed: %w", err))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, "Invalid", response.Reason)
+	assert.Len(t, response.Causes, 1)
+	assert.Equal(t, "spec.replicas", response.Causes[0].Field)
+	assert.Equal(t, "FieldValueInvalid", response.Causes[0].Type)
+}
+
+func TestErrorResponseFor_Invalid(t *testing.T) {
+	err := api.NewInvalid("object doesn't match its schema", []api.ErrorCause{
+		{Type: "FieldValueRequired", Field: "spec.selector", Message: "required field is missing"},
+	})
+
+	code, response := api.ErrorResponseFor(err)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, "unprocessable entity", response.Error)
+	assert.Equal(t, "Invalid", response.Reason)
+	assert.Equal(t, []api.ErrorCause{
+		{Type: "FieldValueRequired", Field: "spec.selector", Message: "required field is missing"},
+	}, response.Causes)
+}
+
+func TestErrorResponseFor_BadRequest(t *testing.T) {
+	code, response := api.ErrorResponseFor(api.NewBadRequest("malformed YAML"))
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "bad request", response.Error)
+	assert.Equal(t, "malformed YAML", response.Message)
+}
+
+func TestErrorResponseFor_UnknownContext(t *testing.T) {
+	code, response := api.ErrorResponseFor(kubeclient.ErrUnknownContext)
+
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "unknown context", response.Error)
+}
+
+func TestErrorResponseFor_Timeout(t *testing.T) {
+	code, _ := api.ErrorResponseFor(context.DeadlineExceeded)
+
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+}
+
+func TestErrorResponseFor_Internal(t *testing.T) {
+	code, response := api.ErrorResponseFor(errors.New("connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "internal server error", response.Error)
+	assert.Empty(t, response.Message)
+}
@@ -81,8 +81,8 @@ func (h *Handler) kubeClient(c *gin.Context, logger *logrus.Entry) (dynamic.Inte
 	kctx := h.clientPool.CurrentContext()
 	client, err := kctx.DynamicClient()
 	if err != nil {
-		logger.WithError(err).Error("can not get k8s client for the context")
-		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return nil, err
 	}
 	return client, nil
 }
@@ -102,16 +102,14 @@ func (h *Handler) List(c *gin.Context) {
 		LabelSelector: c.Query("labelSelector"),
 	})
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't list Kubernetes objects")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		api.AbortWithError(c, logger, err, "Couldn't list Kubernetes objects")
 		return
 	}
 	result, err := convertUnstructuredListToEnvironmentList(list)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't convert Kubernetes objects to environments")
+		return
+	}
 	c.JSON(http.StatusOK, result)
 }
 
@@ -0,0 +1,407 @@
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"io"
+	"k8s-explore/api"
+	"k8s-explore/api/rest/kube/objects"
+	"k8s-explore/kubeclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	crdEstablishTimeout  = 30 * time.Second
+	crdEstablishInterval = 500 * time.Millisecond
+)
+
+const (
+	StatusCreated    = "created"
+	StatusConfigured = "configured"
+	StatusUnchanged  = "unchanged"
+	StatusError      = "error"
+)
+
+var crdResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// kindOrder lists the kinds which have to exist before the objects depending
+// on them, kinds not listed here are applied last in manifest order.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"StorageClass",
+	"ResourceQuota",
+	"LimitRange",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+type Result struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+}
+
+type ApplyResponse struct {
+	DryRun  bool     `json:"dryRun"`
+	Results []Result `json:"results"`
+}
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/manifests", logger),
+		clientPool: clientPool,
+	}
+}
+
+// Apply server-side applies every object of a multi-document manifest, in
+// dependency order. In dry-run mode, the objects depending on the CRDs or the
+// namespaces of the manifest can't be validated, they are reported created.
+func (h *Handler) Apply(c *gin.Context) {
+	logger := h.Logger(c).WithField("method", "Apply").WithField("context", c.Param("ctx"))
+
+	opts, err := objects.ApplyOptionsFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid apply options")
+		return
+	}
+
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't read request body")
+		return
+	}
+	objs, err := splitManifest(body)
+	if err != nil {
+		err = api.NewBadRequest(err.Error())
+		api.AbortWithError(c, logger, err, "Couldn't decode manifest")
+		return
+	}
+	sortByKind(objs)
+
+	discoveryClient, err := kctx.DiscoveryClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes discovery client for context")
+		return
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+
+	namespace := c.Query("namespace")
+	if namespace == "" {
+		namespace = kctx.Namespace()
+	}
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	a := &applier{
+		client:           client,
+		mapper:           restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		dryRun:           opts.DryRun,
+		force:            opts.Force,
+		fieldManager:     opts.FieldManager,
+		namespace:        namespace,
+		bundleCRDs:       bundleCRDs(objs),
+		bundleNamespaces: bundleNamespaces(objs),
+		logger:           logger,
+	}
+	c.JSON(http.StatusOK, ApplyResponse{
+		DryRun:  len(opts.DryRun) > 0,
+		Results: a.applyAll(c.Request.Context(), objs),
+	})
+}
+
+type applier struct {
+	client           dynamic.Interface
+	mapper           *restmapper.DeferredDiscoveryRESTMapper
+	dryRun           []string
+	force            bool
+	fieldManager     string
+	namespace        string
+	bundleCRDs       map[schema.GroupKind]bool
+	bundleNamespaces map[string]bool
+	logger           *logrus.Entry
+}
+
+func (a *applier) applyAll(ctx context.Context, objs []*unstructured.Unstructured) []Result {
+	results := make([]Result, 0, len(objs))
+	var crds []int
+	for i, obj := range objs {
+		// custom resources can only be mapped once their definitions are served
+		if obj.GetKind() != "CustomResourceDefinition" && len(crds) > 0 {
+			a.waitForCRDs(ctx, results, crds)
+			crds = nil
+		}
+		results = append(results, a.apply(ctx, obj))
+		if obj.GetKind() == "CustomResourceDefinition" && results[i].Status != StatusError {
+			crds = append(crds, i)
+		}
+	}
+	if len(crds) > 0 {
+		a.waitForCRDs(ctx, results, crds)
+	}
+	return results
+}
+
+func (a *applier) apply(ctx context.Context, obj *unstructured.Unstructured) Result {
+	result := Result{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+	logger := a.logger.
+		WithField("kind", result.Kind).
+		WithField("objectNamespace", result.Namespace).
+		WithField("objectName", result.Name)
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) && len(a.dryRun) > 0 && a.bundleCRDs[gvk.GroupKind()] {
+			result.Status = StatusCreated
+			result.Message = "kind is defined by a CustomResourceDefinition of this manifest, not validated in dry-run mode"
+			return result
+		}
+		logger.WithError(err).Debug("Couldn't map kind to a resource")
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	var resource dynamic.ResourceInterface
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(a.namespace)
+			result.Namespace = a.namespace
+		}
+		resource = a.client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	} else {
+		obj.SetNamespace("")
+		result.Namespace = ""
+		resource = a.client.Resource(mapping.Resource)
+	}
+
+	live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.WithError(err).Debug("Couldn't get live object")
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+	if err != nil {
+		live = nil
+	}
+
+	applied, err := resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
+		DryRun:       a.dryRun,
+		Force:        a.force,
+		FieldManager: a.fieldManager,
+	})
+	if err != nil && len(a.dryRun) > 0 && a.bundleNamespaces[obj.GetNamespace()] && isNamespaceNotFound(err) {
+		result.Status = StatusCreated
+		result.Message = "namespace is created by this manifest, not validated in dry-run mode"
+		return result
+	}
+	if err != nil {
+		logger.WithError(err).Debug("Couldn't apply object")
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	switch {
+	case live == nil:
+		result.Status = StatusCreated
+	case reflect.DeepEqual(objects.WithoutServerFields(live), objects.WithoutServerFields(applied)):
+		result.Status = StatusUnchanged
+	default:
+		result.Status = StatusConfigured
+	}
+	return result
+}
+
+func (a *applier) waitForCRDs(ctx context.Context, results []Result, crds []int) {
+	if len(a.dryRun) == 0 {
+		for _, i := range crds {
+			if err := a.waitForCRD(ctx, results[i].Name); err != nil {
+				a.logger.
+					WithError(err).
+					WithField("crd", results[i].Name).
+					Warn("CustomResourceDefinition hasn't been established")
+				results[i].Status = StatusError
+				results[i].Message = fmt.Sprintf("not established: %v", err)
+			}
+		}
+	}
+	a.mapper.Reset()
+}
+
+func (a *applier) waitForCRD(ctx context.Context, name string) error {
+	return wait.PollUntilContextTimeout(ctx, crdEstablishInterval, crdEstablishTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			crd, err := a.client.Resource(crdResource).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+			for _, c := range conditions {
+				condition, ok := c.(map[string]interface{})
+				if ok && condition["type"] == "Established" && condition["status"] == "True" {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+}
+
+// bundleCRDs returns the kinds defined by the CRDs of the manifest.
+func bundleCRDs(objs []*unstructured.Unstructured) map[schema.GroupKind]bool {
+	kinds := make(map[schema.GroupKind]bool)
+	for _, obj := range objs {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		kinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+	return kinds
+}
+
+// bundleNamespaces returns the namespaces created by the manifest.
+func bundleNamespaces(objs []*unstructured.Unstructured) map[string]bool {
+	namespaces := make(map[string]bool)
+	for _, obj := range objs {
+		if obj.GetKind() == "Namespace" && obj.GroupVersionKind().Group == "" {
+			namespaces[obj.GetName()] = true
+		}
+	}
+	return namespaces
+}
+
+// isNamespaceNotFound tells whether a write failed because the namespace of
+// the object doesn't exist.
+func isNamespaceNotFound(err error) bool {
+	var status apierrors.APIStatus
+	if !apierrors.IsNotFound(err) || !errors.As(err, &status) {
+		return false
+	}
+	details := status.Status().Details
+	return details != nil && details.Kind == "namespaces"
+}
+
+// splitManifest decodes every YAML or JSON document of the manifest, the
+// items of List documents are flattened.
+func splitManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	for i := 1; ; i++ {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", i, err)
+			}
+			for j := range list.Items {
+				if err := checkIdentity(&list.Items[j]); err != nil {
+					return nil, fmt.Errorf("document %d, item %d: %w", i, j+1, err)
+				}
+				objs = append(objs, &list.Items[j])
+			}
+			continue
+		}
+		if err := checkIdentity(obj); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func checkIdentity(obj *unstructured.Unstructured) error {
+	if obj.GetKind() == "" || obj.GetAPIVersion() == "" || obj.GetName() == "" {
+		return errors.New("apiVersion, kind and metadata.name are required")
+	}
+	return nil
+}
+
+func sortByKind(objs []*unstructured.Unstructured) {
+	rank := func(kind string) int {
+		for i, k := range kindOrder {
+			if k == kind {
+				return i
+			}
+		}
+		return len(kindOrder)
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rank(objs[i].GetKind()) < rank(objs[j].GetKind())
+	})
+}
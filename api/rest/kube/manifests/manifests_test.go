@@ -0,0 +1,120 @@
+package manifests
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/kubeclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const bundle = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: web-config
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: widget
+---
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: shop
+`
+
+func TestSplitManifest(t *testing.T) {
+	objs, err := splitManifest([]byte(bundle))
+	assert.NoError(t, err)
+
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetKind())
+	}
+	assert.Equal(t, []string{"Deployment", "ConfigMap", "Widget", "CustomResourceDefinition", "Namespace"}, kinds)
+}
+
+func TestSplitManifest_MissingName(t *testing.T) {
+	_, err := splitManifest([]byte("apiVersion: v1\nkind: ConfigMap\n"))
+	assert.Error(t, err)
+}
+
+func TestSplitManifest_ListItemMissingName(t *testing.T) {
+	_, err := splitManifest([]byte("apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: ConfigMap\n"))
+	assert.EqualError(t, err, "document 1, item 1: apiVersion, kind and metadata.name are required")
+}
+
+func TestSortByKind(t *testing.T) {
+	objs, err := splitManifest([]byte(bundle))
+	assert.NoError(t, err)
+
+	sortByKind(objs)
+
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetKind())
+	}
+	assert.Equal(t, []string{"Namespace", "CustomResourceDefinition", "ConfigMap", "Deployment", "Widget"}, kinds)
+}
+
+func TestBundleCRDs(t *testing.T) {
+	objs, err := splitManifest([]byte(bundle))
+	assert.NoError(t, err)
+
+	kinds := bundleCRDs(objs)
+	assert.Len(t, kinds, 1)
+	for gk := range kinds {
+		assert.Equal(t, "example.com", gk.Group)
+		assert.Equal(t, "Widget", gk.Kind)
+	}
+}
+
+func TestBundleNamespaces(t *testing.T) {
+	objs, err := splitManifest([]byte(bundle))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{"shop": true}, bundleNamespaces(objs))
+}
+
+func TestIsNamespaceNotFound(t *testing.T) {
+	assert.True(t, isNamespaceNotFound(apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "shop")))
+	assert.False(t, isNamespaceNotFound(apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "web")))
+	assert.False(t, isNamespaceNotFound(apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "shop", nil)))
+}
+
+func TestApply_InvalidOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(kubeclient.NewPool(), logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.POST("/:ctx/manifests/apply", h.Apply)
+
+	for _, query := range []string{"force=yes", "dryRun=true"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/dev/manifests/apply?"+query, strings.NewReader(bundle)))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
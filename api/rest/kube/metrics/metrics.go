@@ -0,0 +1,419 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/kubeclient"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"math"
+	"net/http"
+	"sort"
+)
+
+var (
+	metricsGroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+	podMetricsResource  = metricsGroupVersion.WithResource("pods")
+	nodeMetricsResource = metricsGroupVersion.WithResource("nodes")
+)
+
+// Usage is the use of a resource, in percent of what the spec reserves or
+// caps when it does.
+type Usage struct {
+	Usage              resource.Quantity  `json:"usage"`
+	Request            *resource.Quantity `json:"request,omitempty"`
+	Limit              *resource.Quantity `json:"limit,omitempty"`
+	Allocatable        *resource.Quantity `json:"allocatable,omitempty"`
+	RequestPercent     *float64           `json:"requestPercent,omitempty"`
+	LimitPercent       *float64           `json:"limitPercent,omitempty"`
+	AllocatablePercent *float64           `json:"allocatablePercent,omitempty"`
+}
+
+type ContainerMetrics struct {
+	Name   string `json:"name"`
+	CPU    Usage  `json:"cpu"`
+	Memory Usage  `json:"memory"`
+}
+
+// PodMetrics sums the containers of a pod. The pod has a limit only when
+// all of its containers have one.
+type PodMetrics struct {
+	Namespace  string             `json:"namespace"`
+	Name       string             `json:"name"`
+	Timestamp  metav1.Time        `json:"timestamp"`
+	Window     metav1.Duration    `json:"window"`
+	CPU        Usage              `json:"cpu"`
+	Memory     Usage              `json:"memory"`
+	Containers []ContainerMetrics `json:"containers"`
+}
+
+type NodeMetrics struct {
+	Name      string          `json:"name"`
+	Timestamp metav1.Time     `json:"timestamp"`
+	Window    metav1.Duration `json:"window"`
+	CPU       Usage           `json:"cpu"`
+	Memory    Usage           `json:"memory"`
+}
+
+// podMetricsItem and nodeMetricsItem decode the objects of metrics.k8s.io,
+// served by metrics-server.
+type podMetricsItem struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Timestamp         metav1.Time            `json:"timestamp"`
+	Window            metav1.Duration        `json:"window"`
+	Containers        []containerMetricsItem `json:"containers"`
+}
+
+type containerMetricsItem struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+type nodeMetricsItem struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Timestamp         metav1.Time         `json:"timestamp"`
+	Window            metav1.Duration     `json:"window"`
+	Usage             corev1.ResourceList `json:"usage"`
+}
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/metrics", logger),
+		clientPool: clientPool,
+	}
+}
+
+// Pods returns the usage of the pods of a namespace, or of every namespace
+// without one, matching the labelSelector parameter. Pods metrics-server
+// hasn't scraped yet are left out.
+func (h *Handler) Pods(c *gin.Context) {
+	logger := h.loggerFor(c, "Pods")
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	ctx := c.Request.Context()
+	opts := metav1.ListOptions{LabelSelector: c.Query("labelSelector")}
+	items, err := listMetrics(ctx, kctx, podMetricsResource, c.Param("namespace"), opts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list pod metrics")
+		return
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	pods, err := client.CoreV1().Pods(c.Param("namespace")).List(ctx, opts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list pods")
+		return
+	}
+	specs := make(map[string]*corev1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		specs[pods.Items[i].Namespace+"/"+pods.Items[i].Name] = &pods.Items[i]
+	}
+
+	result := make([]PodMetrics, 0, len(items))
+	for _, item := range items {
+		m := podMetricsItem{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &m); err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't decode pod metrics")
+			return
+		}
+		result = append(result, podMetrics(m, specs[m.Namespace+"/"+m.Name]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) Pod(c *gin.Context) {
+	logger := h.loggerFor(c, "Pod")
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	ctx := c.Request.Context()
+	item, err := getMetrics(ctx, kctx, podMetricsResource, c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get pod metrics")
+		return
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	pod, err := client.CoreV1().Pods(c.Param("namespace")).Get(ctx, c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get pod")
+		return
+	}
+	m := podMetricsItem{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &m); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't decode pod metrics")
+		return
+	}
+	c.JSON(http.StatusOK, podMetrics(m, pod))
+}
+
+// Nodes returns the usage of the nodes matching the labelSelector parameter.
+func (h *Handler) Nodes(c *gin.Context) {
+	logger := h.loggerFor(c, "Nodes")
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	ctx := c.Request.Context()
+	opts := metav1.ListOptions{LabelSelector: c.Query("labelSelector")}
+	items, err := listMetrics(ctx, kctx, nodeMetricsResource, "", opts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list node metrics")
+		return
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	nodes, err := client.CoreV1().Nodes().List(ctx, opts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list nodes")
+		return
+	}
+	specs := make(map[string]*corev1.Node, len(nodes.Items))
+	for i := range nodes.Items {
+		specs[nodes.Items[i].Name] = &nodes.Items[i]
+	}
+
+	result := make([]NodeMetrics, 0, len(items))
+	for _, item := range items {
+		m := nodeMetricsItem{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &m); err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't decode node metrics")
+			return
+		}
+		result = append(result, nodeMetrics(m, specs[m.Name]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) Node(c *gin.Context) {
+	logger := h.loggerFor(c, "Node")
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	ctx := c.Request.Context()
+	item, err := getMetrics(ctx, kctx, nodeMetricsResource, "", c.Param("name"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get node metrics")
+		return
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	node, err := client.CoreV1().Nodes().Get(ctx, c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get node")
+		return
+	}
+	m := nodeMetricsItem{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &m); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't decode node metrics")
+		return
+	}
+	c.JSON(http.StatusOK, nodeMetrics(m, node))
+}
+
+func listMetrics(ctx context.Context, kctx *kubeclient.Context, gvr schema.GroupVersionResource, namespace string, opts metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	if err := metricsAvailable(kctx); err != nil {
+		return nil, err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.Resource(gvr).Namespace(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, metricsError(kctx, err)
+	}
+	return list.Items, nil
+}
+
+func getMetrics(ctx context.Context, kctx *kubeclient.Context, gvr schema.GroupVersionResource, namespace string, name string) (*unstructured.Unstructured, error) {
+	if err := metricsAvailable(kctx); err != nil {
+		return nil, err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	item, err := client.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, metricsError(kctx, err)
+	}
+	return item, nil
+}
+
+// metricsAvailable tells apart a cluster without metrics-server, where the
+// metrics API isn't served at all.
+func metricsAvailable(kctx *kubeclient.Context) error {
+	discoveryClient, err := kctx.DiscoveryClient()
+	if err != nil {
+		return err
+	}
+	_, err = discoveryClient.ServerResourcesForGroupVersion(metricsGroupVersion.String())
+	if apierrors.IsNotFound(err) {
+		return metricsUnavailable(kctx)
+	}
+	return metricsError(kctx, err)
+}
+
+// metricsError reports the metrics API being registered but down, as when
+// metrics-server isn't running, like a missing one.
+func metricsError(kctx *kubeclient.Context, err error) error {
+	if apierrors.IsServiceUnavailable(err) {
+		return metricsUnavailable(kctx)
+	}
+	return err
+}
+
+func metricsUnavailable(kctx *kubeclient.Context) error {
+	return apierrors.NewServiceUnavailable(fmt.Sprintf(
+		"the metrics API (%s) isn't available in context %s, metrics-server is likely not installed",
+		metricsGroupVersion, kctx.Name()))
+}
+
+func podMetrics(m podMetricsItem, pod *corev1.Pod) PodMetrics {
+	result := PodMetrics{
+		Namespace:  m.Namespace,
+		Name:       m.Name,
+		Timestamp:  m.Timestamp,
+		Window:     m.Window,
+		Containers: make([]ContainerMetrics, 0, len(m.Containers)),
+	}
+	specs := make(map[string]corev1.ResourceRequirements)
+	if pod != nil {
+		for _, container := range pod.Spec.Containers {
+			specs[container.Name] = container.Resources
+		}
+	}
+
+	podUsage, podRequests, podLimits := corev1.ResourceList{}, corev1.ResourceList{}, corev1.ResourceList{}
+	// the pod requests and limits are only known when every container sets them
+	requested := map[corev1.ResourceName]bool{corev1.ResourceCPU: pod != nil, corev1.ResourceMemory: pod != nil}
+	limited := map[corev1.ResourceName]bool{corev1.ResourceCPU: pod != nil, corev1.ResourceMemory: pod != nil}
+	for _, container := range m.Containers {
+		resources := specs[container.Name]
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			addQuantity(podUsage, name, container.Usage)
+			if _, found := resources.Requests[name]; found {
+				addQuantity(podRequests, name, resources.Requests)
+			} else {
+				requested[name] = false
+			}
+			if _, found := resources.Limits[name]; found {
+				addQuantity(podLimits, name, resources.Limits)
+			} else {
+				limited[name] = false
+			}
+		}
+		result.Containers = append(result.Containers, ContainerMetrics{
+			Name:   container.Name,
+			CPU:    usage(container.Usage, resources.Requests, resources.Limits, nil, corev1.ResourceCPU),
+			Memory: usage(container.Usage, resources.Requests, resources.Limits, nil, corev1.ResourceMemory),
+		})
+	}
+	for name, isRequested := range requested {
+		if !isRequested {
+			delete(podRequests, name)
+		}
+	}
+	for name, isLimited := range limited {
+		if !isLimited {
+			delete(podLimits, name)
+		}
+	}
+	result.CPU = usage(podUsage, podRequests, podLimits, nil, corev1.ResourceCPU)
+	result.Memory = usage(podUsage, podRequests, podLimits, nil, corev1.ResourceMemory)
+	return result
+}
+
+func nodeMetrics(m nodeMetricsItem, node *corev1.Node) NodeMetrics {
+	var allocatable corev1.ResourceList
+	if node != nil {
+		allocatable = node.Status.Allocatable
+	}
+	return NodeMetrics{
+		Name:      m.Name,
+		Timestamp: m.Timestamp,
+		Window:    m.Window,
+		CPU:       usage(m.Usage, nil, nil, allocatable, corev1.ResourceCPU),
+		Memory:    usage(m.Usage, nil, nil, allocatable, corev1.ResourceMemory),
+	}
+}
+
+func usage(used, requests, limits, allocatable corev1.ResourceList, name corev1.ResourceName) Usage {
+	u := Usage{Usage: used[name]}
+	u.Request, u.RequestPercent = reference(u.Usage, requests, name)
+	u.Limit, u.LimitPercent = reference(u.Usage, limits, name)
+	u.Allocatable, u.AllocatablePercent = reference(u.Usage, allocatable, name)
+	return u
+}
+
+// reference returns a quantity of the spec and the usage in percent of it,
+// rounded to two decimals. Zero quantities are as good as missing.
+func reference(used resource.Quantity, list corev1.ResourceList, name corev1.ResourceName) (*resource.Quantity, *float64) {
+	q, found := list[name]
+	if !found || q.IsZero() {
+		return nil, nil
+	}
+	percent := math.Round(used.AsApproximateFloat64()/q.AsApproximateFloat64()*10000) / 100
+	return &q, &percent
+}
+
+func addQuantity(sum corev1.ResourceList, name corev1.ResourceName, list corev1.ResourceList) {
+	q, found := list[name]
+	if !found {
+		return
+	}
+	total := sum[name]
+	total.Add(q)
+	sum[name] = total
+}
+
+func (h *Handler) loggerFor(c *gin.Context, methodName string) *logrus.Entry {
+	return h.Logger(c).
+		WithField("method", methodName).
+		WithField("context", c.Param("ctx")).
+		WithField("namespace", c.Param("namespace")).
+		WithField("name", c.Param("name"))
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func resources(cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if cpu != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+func TestPodMetrics(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "app", Resources: corev1.ResourceRequirements{
+			Requests: resources("200m", "256Mi"),
+			Limits:   resources("1", "512Mi"),
+		}},
+		{Name: "sidecar", Resources: corev1.ResourceRequirements{
+			Requests: resources("50m", ""),
+			Limits:   resources("", "64Mi"),
+		}},
+	}}}
+	m := podMetricsItem{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-0"}}
+	m.Containers = append(m.Containers,
+		containerMetricsItem{Name: "app", Usage: resources("100m", "128Mi")},
+		containerMetricsItem{Name: "sidecar", Usage: resources("25m", "32Mi")},
+	)
+
+	result := podMetrics(m, pod)
+	assert.Equal(t, "web-0", result.Name)
+
+	app := result.Containers[0]
+	assert.Equal(t, 50.0, *app.CPU.RequestPercent)
+	assert.Equal(t, 10.0, *app.CPU.LimitPercent)
+	assert.Equal(t, 50.0, *app.Memory.RequestPercent)
+	assert.Equal(t, 25.0, *app.Memory.LimitPercent)
+
+	sidecar := result.Containers[1]
+	assert.Nil(t, sidecar.CPU.Limit)
+	assert.Nil(t, sidecar.CPU.LimitPercent)
+	assert.Nil(t, sidecar.Memory.RequestPercent)
+
+	assert.Equal(t, "125m", result.CPU.Usage.String())
+	assert.Equal(t, "250m", result.CPU.Request.String())
+	assert.Equal(t, 50.0, *result.CPU.RequestPercent)
+	// the sidecar has no CPU limit, so neither has the pod
+	assert.Nil(t, result.CPU.LimitPercent)
+	// nor a memory request
+	assert.Nil(t, result.Memory.Request)
+	assert.Nil(t, result.Memory.RequestPercent)
+	assert.Equal(t, "576Mi", result.Memory.Limit.String())
+	assert.Equal(t, 27.78, *result.Memory.LimitPercent)
+}
+
+func TestPodMetrics_WithoutPod(t *testing.T) {
+	m := podMetricsItem{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-0"}}
+	m.Containers = append(m.Containers, containerMetricsItem{Name: "app", Usage: resources("100m", "128Mi")})
+
+	result := podMetrics(m, nil)
+	assert.Equal(t, "100m", result.CPU.Usage.String())
+	assert.Nil(t, result.CPU.RequestPercent)
+	assert.Nil(t, result.Memory.LimitPercent)
+}
+
+func TestNodeMetrics(t *testing.T) {
+	node := &corev1.Node{Status: corev1.NodeStatus{Allocatable: resources("4", "16Gi")}}
+	m := nodeMetricsItem{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}, Usage: resources("1500m", "4Gi")}
+
+	result := nodeMetrics(m, node)
+	assert.Equal(t, "node-a", result.Name)
+	assert.Equal(t, 37.5, *result.CPU.AllocatablePercent)
+	assert.Equal(t, 25.0, *result.Memory.AllocatablePercent)
+	assert.Nil(t, result.CPU.RequestPercent)
+}
@@ -0,0 +1,269 @@
+package namespaces
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/api/rest/kube/objects"
+	"k8s-explore/kubeclient"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"net/http"
+	"path"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// skippedFile lists what couldn't be exported, the archive is already being
+// sent when a list fails.
+const skippedFile = "SKIPPED"
+
+// skippedGroupResources are generated by the cluster and make no sense in
+// a portable bundle.
+var skippedGroupResources = map[schema.GroupResource]bool{
+	{Group: "", Resource: "events"}:                         true,
+	{Group: "events.k8s.io", Resource: "events"}:            true,
+	{Group: "", Resource: "endpoints"}:                      true,
+	{Group: "discovery.k8s.io", Resource: "endpointslices"}: true,
+	{Group: "metrics.k8s.io", Resource: "pods"}:             true,
+	{Group: "coordination.k8s.io", Resource: "leases"}:      true,
+}
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/namespaces", logger),
+		clientPool: clientPool,
+	}
+}
+
+// Export streams the objects of a namespace as a tar.gz of clean manifests,
+// one YAML file per object laid out as kind/name.yaml. Objects owned by a
+// controller are left out since their owner re-creates them. The resources
+// and objects which couldn't be exported are named in a SKIPPED file.
+func (h *Handler) Export(c *gin.Context) {
+	logger := h.Logger(c).
+		WithField("method", "Export").
+		WithField("context", c.Param("ctx")).
+		WithField("namespace", c.Param("namespace"))
+	namespace := c.Param("namespace")
+
+	withKustomization := false
+	if k := c.Query("kustomization"); k != "" {
+		b, err := strconv.ParseBool(k)
+		if err != nil {
+			api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid kustomization value %q", k)), "Invalid export options")
+			return
+		}
+		withKustomization = b
+	}
+
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	discoveryClient, err := kctx.DiscoveryClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes discovery client for context")
+		return
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	if _, err := client.
+		Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).
+		Get(c.Request.Context(), namespace, metav1.GetOptions{}); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get namespace")
+		return
+	}
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		api.AbortWithError(c, logger, err, "Couldn't load Kubernetes preferred resources")
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", namespace+".tar.gz"))
+	c.Status(http.StatusOK)
+	gz := gzip.NewWriter(c.Writer)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	var files, skipped []string
+	for _, gvr := range exportableResources(resourceLists) {
+		list, err := client.Resource(gvr).Namespace(namespace).List(c.Request.Context(), metav1.ListOptions{})
+		if err != nil {
+			logger.
+				WithError(err).
+				WithField("exportResource", gvr.String()).
+				Warn("Couldn't list Kubernetes objects, skipping them")
+			skipped = append(skipped, fmt.Sprintf("%s: %v", gvr.GroupResource(), err))
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !exportable(obj) {
+				continue
+			}
+			name := objectPath(gvr.Group, obj)
+			data, err := yaml.Marshal(cleanObject(obj).Object)
+			if err != nil {
+				logger.
+					WithError(err).
+					WithField("objectName", obj.GetName()).
+					Warn("Couldn't encode Kubernetes object, skipping it")
+				skipped = append(skipped, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			if err := writeFile(tw, name, data, now); err != nil {
+				logger.WithError(err).Error("Couldn't write export archive")
+				return
+			}
+			files = append(files, name)
+		}
+	}
+
+	if len(skipped) > 0 {
+		data := []byte(strings.Join(skipped, "\n") + "\n")
+		if err := writeFile(tw, skippedFile, data, now); err != nil {
+			logger.WithError(err).Error("Couldn't write skipped resources to export archive")
+			return
+		}
+	}
+
+	if withKustomization {
+		sort.Strings(files)
+		data, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "kustomize.config.k8s.io/v1beta1",
+			"kind":       "Kustomization",
+			"namespace":  namespace,
+			"resources":  files,
+		})
+		if err == nil {
+			err = writeFile(tw, "kustomization.yaml", data, now)
+		}
+		if err != nil {
+			logger.WithError(err).Error("Couldn't write kustomization to export archive")
+			return
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		logger.WithError(err).Error("Couldn't close export archive")
+		return
+	}
+	if err := gz.Close(); err != nil {
+		logger.WithError(err).Error("Couldn't close export archive")
+	}
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// exportableResources keeps the namespaced top level resources which can be
+// listed, sorted for a stable archive layout.
+func exportableResources(resourceLists []*metav1.APIResourceList) []schema.GroupVersionResource {
+	var resources []schema.GroupVersionResource
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if !r.Namespaced || strings.Contains(r.Name, "/") || !objects.HasVerb(r.Verbs, "list") {
+				continue
+			}
+			if skippedGroupResources[gv.WithResource(r.Name).GroupResource()] {
+				continue
+			}
+			resources = append(resources, gv.WithResource(r.Name))
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].String() < resources[j].String()
+	})
+	return resources
+}
+
+// exportable tells whether the object belongs in the bundle: objects owned by
+// controllers and the ones the cluster creates in every namespace don't.
+func exportable(obj *unstructured.Unstructured) bool {
+	if metav1.GetControllerOf(obj) != nil {
+		return false
+	}
+	switch obj.GetKind() {
+	case "ServiceAccount":
+		return obj.GetName() != "default"
+	case "ConfigMap":
+		return obj.GetName() != "kube-root-ca.crt"
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType != "kubernetes.io/service-account-token"
+	}
+	return true
+}
+
+// cleanObject drops the status and the metadata populated by the server, and
+// the cluster IPs of services which another cluster would refuse. Headless
+// services keep theirs.
+func cleanObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "status")
+	if obj.GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Service"}) {
+		if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != corev1.ClusterIPNone {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+	}
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetManagedFields(nil)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetSelfLink("")
+	annotations := obj.GetAnnotations()
+	delete(annotations, lastAppliedAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+// objectPath lays objects out as kind/name.yaml, the group qualifies the
+// kind of non core objects to keep paths unique.
+func objectPath(group string, obj *unstructured.Unstructured) string {
+	dir := strings.ToLower(obj.GetKind())
+	if group != "" {
+		dir += "." + group
+	}
+	return path.Join(dir, obj.GetName()+".yaml")
+}
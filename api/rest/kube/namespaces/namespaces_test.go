@@ -0,0 +1,165 @@
+package namespaces
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"k8s-explore/kubeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanObject(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":              "web",
+			"namespace":         "preview-42",
+			"uid":               "3f1c",
+			"resourceVersion":   "1234",
+			"generation":        int64(3),
+			"creationTimestamp": "2026-10-01T12:00:00Z",
+			"managedFields":     []interface{}{map[string]interface{}{"manager": "kubectl"}},
+			"labels":            map[string]interface{}{"app": "web"},
+			"annotations": map[string]interface{}{
+				lastAppliedAnnotation: "{}",
+			},
+		},
+		"spec":   map[string]interface{}{"replicas": int64(2)},
+		"status": map[string]interface{}{"readyReplicas": int64(2)},
+	}}
+
+	cleaned := cleanObject(obj)
+
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "preview-42",
+			"labels":    map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{"replicas": int64(2)},
+	}, cleaned.Object)
+	assert.Contains(t, obj.Object, "status", "the original object is left untouched")
+}
+
+func TestCleanObject_Service(t *testing.T) {
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "preview-42"},
+		"spec": map[string]interface{}{
+			"clusterIP":  "10.96.12.7",
+			"clusterIPs": []interface{}{"10.96.12.7"},
+			"ports":      []interface{}{map[string]interface{}{"port": int64(80)}},
+		},
+	}}
+	assert.Equal(t, map[string]interface{}{
+		"ports": []interface{}{map[string]interface{}{"port": int64(80)}},
+	}, cleanObject(service).Object["spec"])
+
+	headless := service.DeepCopy()
+	assert.NoError(t, unstructured.SetNestedField(headless.Object, "None", "spec", "clusterIP"))
+	assert.NoError(t, unstructured.SetNestedStringSlice(headless.Object, []string{"None"}, "spec", "clusterIPs"))
+	assert.Equal(t, headless.Object["spec"], cleanObject(headless).Object["spec"])
+}
+
+func TestExportable(t *testing.T) {
+	controller := true
+	pod := &unstructured.Unstructured{}
+	pod.SetKind("Pod")
+	pod.SetName("web-7d9f-abcde")
+	pod.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       "ReplicaSet",
+		Name:       "web-7d9f",
+		UID:        "42",
+		Controller: &controller,
+	}})
+	assert.False(t, exportable(pod))
+
+	sa := &unstructured.Unstructured{}
+	sa.SetKind("ServiceAccount")
+	sa.SetName("default")
+	assert.False(t, exportable(sa))
+
+	cm := &unstructured.Unstructured{}
+	cm.SetKind("ConfigMap")
+	cm.SetName("web-config")
+	assert.True(t, exportable(cm))
+
+	assert.Equal(t, "configmap/web-config.yaml", objectPath("", cm))
+	pod.SetKind("Deployment")
+	assert.Equal(t, "deployment.apps/web-7d9f-abcde.yaml", objectPath("apps", pod))
+}
+
+func TestExport_Skipped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/kube-system", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"kube-system","uid":"uid-dev"}}`)
+	})
+	mux.HandleFunc("/api/v1/namespaces/shop", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"shop"}}`)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+	})
+	mux.HandleFunc("/apis", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`)
+	})
+	mux.HandleFunc("/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"v1","resources":[`+
+			`{"name":"configmaps","namespaced":true,"kind":"ConfigMap","verbs":["list"]},`+
+			`{"name":"secrets","namespaced":true,"kind":"Secret","verbs":["list"]}]}`)
+	})
+	mux.HandleFunc("/api/v1/namespaces/shop/configmaps", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"ConfigMapList","items":[`+
+			`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"shop"}}]}`)
+	})
+	mux.HandleFunc("/api/v1/namespaces/shop/secrets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"Status","status":"Failure","reason":"Forbidden","code":403,`+
+			`"message":"secrets is forbidden"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	pool := kubeclient.NewPool()
+	assert.NoError(t, pool.Add(context.Background(), "dev", "dev", "dev", "shop", &rest.Config{Host: server.URL}))
+
+	h := NewHandler(pool, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.GET("/:ctx/namespaces/:namespace/export", h.Export)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dev/namespaces/shop/export", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	gz, err := gzip.NewReader(recorder.Body)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+	assert.Contains(t, files, "configmap/web.yaml")
+	assert.Equal(t, "secrets: secrets is forbidden\n", files[skippedFile])
+}
@@ -0,0 +1,61 @@
+package nodes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/drain"
+	"k8s-explore/kubeclient"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+)
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/nodes", logger),
+		clientPool: clientPool,
+	}
+}
+
+// Cordon marks the node unschedulable, its pods keep running. The
+// nodes.drain call evicts them.
+func (h *Handler) Cordon(c *gin.Context) {
+	h.setUnschedulable(c, "Cordon", true)
+}
+
+func (h *Handler) Uncordon(c *gin.Context) {
+	h.setUnschedulable(c, "Uncordon", false)
+}
+
+func (h *Handler) setUnschedulable(c *gin.Context, methodName string, unschedulable bool) {
+	logger := h.Logger(c).
+		WithField("method", methodName).
+		WithField("context", c.Param("ctx")).
+		WithField("node", c.Param("name"))
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+	var node *corev1.Node
+	if unschedulable {
+		node, err = drain.Cordon(c.Request.Context(), client, c.Param("name"))
+	} else {
+		node, err = drain.Uncordon(c.Request.Context(), client, c.Param("name"))
+	}
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't update node")
+		return
+	}
+	c.JSON(http.StatusOK, node)
+}
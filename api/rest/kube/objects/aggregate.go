@@ -0,0 +1,154 @@
+package objects
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"k8s-explore/api"
+	"k8s-explore/kubeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultContextTimeout = 10 * time.Second
+
+// ContextObject is an object listed from one of the contexts of the pool.
+type ContextObject struct {
+	Context    string                     `json:"context"`
+	ClusterUID string                     `json:"clusterUID"`
+	Object     *unstructured.Unstructured `json:"object"`
+}
+
+// ContextError is the failure of the list call of a single context.
+type ContextError struct {
+	api.ErrorResponse
+	Context    string `json:"context"`
+	ClusterUID string `json:"clusterUID,omitempty"`
+	Code       int    `json:"code"`
+}
+
+type AggregatedList struct {
+	Items  []ContextObject `json:"items"`
+	Errors []ContextError  `json:"errors"`
+}
+
+// ListAll lists the objects of a resource in every context of the pool, or in
+// the ones given by the contexts parameters, in parallel. A failing context is
+// reported in the errors section and doesn't fail the whole request.
+func (h *Handler) ListAll(c *gin.Context) {
+	logger := getLogger(c, h, "ListAll")
+
+	timeout := defaultContextTimeout
+	if t := c.Query("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid timeout value %q", t)), "Invalid list options")
+			return
+		}
+		timeout = d
+	}
+	opts := metav1.ListOptions{
+		FieldSelector: c.Query("fieldSelector"),
+		LabelSelector: c.Query("labelSelector"),
+	}
+
+	result := AggregatedList{Items: []ContextObject{}, Errors: []ContextError{}}
+	var kctxs []*kubeclient.Context
+	if names := strings.Join(c.QueryArray("contexts"), ","); names != "" {
+		listed := make(map[string]bool)
+		for _, name := range strings.Split(names, ",") {
+			if listed[name] {
+				continue
+			}
+			listed[name] = true
+			kctx, err := h.clientPool.Context(name)
+			if err != nil {
+				result.Errors = append(result.Errors, contextError(name, "", err))
+				continue
+			}
+			kctxs = append(kctxs, kctx)
+		}
+	} else {
+		kctxs = h.clientPool.Contexts()
+	}
+
+	gvr := groupVersionResource(c)
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for _, kctx := range kctxs {
+		wg.Add(1)
+		go func(kctx *kubeclient.Context) {
+			defer wg.Done()
+			items, err := listInContext(c.Request.Context(), kctx, gvr, c.Param("namespace"), opts, timeout)
+			mux.Lock()
+			defer mux.Unlock()
+			if err != nil {
+				logger.
+					WithError(err).
+					WithField("listContext", kctx.Name()).
+					Warn("Couldn't list Kubernetes objects in context")
+				result.Errors = append(result.Errors, contextError(kctx.Name(), kctx.ClusterUID(), err))
+				return
+			}
+			result.Items = append(result.Items, items...)
+		}(kctx)
+	}
+	wg.Wait()
+
+	sort.SliceStable(result.Items, func(i, j int) bool {
+		return result.Items[i].Context < result.Items[j].Context
+	})
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Context < result.Errors[j].Context
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func listInContext(
+	ctx context.Context,
+	kctx *kubeclient.Context,
+	gvr schema.GroupVersionResource,
+	namespace string,
+	opts metav1.ListOptions,
+	timeout time.Duration,
+) ([]ContextObject, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.Resource(gvr).Namespace(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ContextObject, 0, len(list.Items))
+	for i := range list.Items {
+		items = append(items, ContextObject{
+			Context:    kctx.Name(),
+			ClusterUID: kctx.ClusterUID(),
+			Object:     &list.Items[i],
+		})
+	}
+	return items, nil
+}
+
+func contextError(name string, clusterUID string, err error) ContextError {
+	code, response := api.ErrorResponseFor(err)
+	if response.Message == "" {
+		// unreachable clusters are the usual suspects here, tell why
+		response.Message = err.Error()
+	}
+	return ContextError{
+		ErrorResponse: response,
+		Context:       name,
+		ClusterUID:    clusterUID,
+		Code:          code,
+	}
+}
@@ -0,0 +1,123 @@
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/kubeclient"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeCluster serves the calls of a context: the kube-system namespace read
+// when the context is added, then the config maps of the shop namespace.
+func fakeCluster(t *testing.T, uid string, listConfigMaps http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/kube-system", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"kube-system","uid":%q}}`, uid)
+	})
+	mux.HandleFunc("/api/v1/namespaces/shop/configmaps", listConfigMaps)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func configMaps(names ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			items = append(items, map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": name, "namespace": "shop"},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMapList", "items": items})
+	}
+}
+
+func TestListAll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pool := kubeclient.NewPool()
+	clusters := map[string]http.HandlerFunc{
+		"blue":  configMaps("web", "api"),
+		"green": configMaps("web"),
+		"red": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"apiVersion":"v1","kind":"Status","status":"Failure","reason":"Forbidden","code":403,`+
+				`"message":"configmaps is forbidden: User \"dev\" cannot list resource \"configmaps\" in the namespace \"shop\""}`)
+		},
+		"slow": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		},
+	}
+	for name, list := range clusters {
+		server := fakeCluster(t, "uid-"+name, list)
+		assert.NoError(t, pool.Add(context.Background(), name, "dev", name, "shop", &rest.Config{Host: server.URL}))
+	}
+	h := NewHandler(pool, nil, nil, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.GET("/:group/:version/namespaces/:namespace/:resource/", h.ListAll)
+
+	type item struct{ context, name string }
+	type failure struct {
+		context string
+		code    int
+	}
+	tests := []struct {
+		name   string
+		query  string
+		items  []item
+		errors []failure
+	}{
+		{
+			name:   "every context",
+			query:  "timeout=200ms",
+			items:  []item{{"blue", "web"}, {"blue", "api"}, {"green", "web"}},
+			errors: []failure{{"red", http.StatusForbidden}, {"slow", http.StatusGatewayTimeout}},
+		},
+		{
+			name:   "given contexts",
+			query:  "contexts=green,red,gone",
+			items:  []item{{"green", "web"}},
+			errors: []failure{{"gone", http.StatusNotFound}, {"red", http.StatusForbidden}},
+		},
+		{
+			name:   "repeated contexts",
+			query:  "contexts=green,gone,green&contexts=gone",
+			items:  []item{{"green", "web"}},
+			errors: []failure{{"gone", http.StatusNotFound}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/core/v1/namespaces/shop/configmaps/?"+test.query, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var result AggregatedList
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+			var items []item
+			for _, i := range result.Items {
+				assert.Equal(t, "uid-"+i.Context, i.ClusterUID)
+				items = append(items, item{i.Context, i.Object.GetName()})
+			}
+			assert.Equal(t, test.items, items)
+			var errors []failure
+			for _, e := range result.Errors {
+				assert.NotEmpty(t, e.Message)
+				errors = append(errors, failure{e.Context, e.Code})
+			}
+			assert.Equal(t, test.errors, errors)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s-explore/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+type Change struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type DiffResult struct {
+	Diff    string   `json:"diff"`
+	Changes []Change `json:"changes"`
+}
+
+// Diff runs the update of the object in the request body as a server-side
+// dry run and reports how the result differs from the live object.
+func (h *Handler) Diff(c *gin.Context) {
+	logger := getLogger(c, h, "Diff")
+
+	obj, err := h.unstructuredObjectFromRequest(c, logger)
+	if err != nil {
+		return
+	}
+	if obj.GetName() != "" && obj.GetName() != c.Param("name") {
+		err := api.NewBadRequest(fmt.Sprintf("object name %q doesn't match the URL", obj.GetName()))
+		api.AbortWithError(c, logger, err, "Object name doesn't match the URL")
+		return
+	}
+	obj.SetName(c.Param("name"))
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	live, err := resource.Get(c.Request.Context(), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes object")
+		return
+	}
+
+	updated, err := resource.Update(c.Request.Context(), obj, metav1.UpdateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't dry-run update of Kubernetes object")
+		return
+	}
+
+	result, err := diffObjects(live, updated)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't diff Kubernetes objects")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func diffObjects(live, updated *unstructured.Unstructured) (*DiffResult, error) {
+	from, to := WithoutServerFields(live), WithoutServerFields(updated)
+	fromYAML, err := yaml.Marshal(from)
+	if err != nil {
+		return nil, err
+	}
+	toYAML, err := yaml.Marshal(to)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(fromYAML)),
+		B:        difflib.SplitLines(string(toYAML)),
+		FromFile: "live",
+		ToFile:   "updated",
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DiffResult{
+		Diff:    diff,
+		Changes: changedPaths("", from, to, []Change{}),
+	}, nil
+}
+
+// WithoutServerFields drops the fields the server changes on every write.
+func WithoutServerFields(obj *unstructured.Unstructured) map[string]interface{} {
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	return obj.Object
+}
+
+// changedPaths walks both values and collects the JSON paths of the leaves
+// which have been added, removed or changed.
+func changedPaths(path string, from, to interface{}, changes []Change) []Change {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		toValue, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(fromValue)+len(toValue))
+		for k := range fromValue {
+			keys = append(keys, k)
+		}
+		for k := range toValue {
+			if _, found := fromValue[k]; !found {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			f, inFrom := fromValue[k]
+			t, inTo := toValue[k]
+			switch {
+			case !inFrom:
+				changes = append(changes, Change{Path: p, Type: ChangeAdded})
+			case !inTo:
+				changes = append(changes, Change{Path: p, Type: ChangeRemoved})
+			default:
+				changes = changedPaths(p, f, t, changes)
+			}
+		}
+		return changes
+	case []interface{}:
+		toValue, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(fromValue) || i < len(toValue); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(fromValue):
+				changes = append(changes, Change{Path: p, Type: ChangeAdded})
+			case i >= len(toValue):
+				changes = append(changes, Change{Path: p, Type: ChangeRemoved})
+			default:
+				changes = changedPaths(p, fromValue[i], toValue[i], changes)
+			}
+		}
+		return changes
+	}
+	if !reflect.DeepEqual(from, to) {
+		changes = append(changes, Change{Path: path, Type: ChangeChanged})
+	}
+	return changes
+}
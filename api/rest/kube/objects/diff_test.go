@@ -0,0 +1,83 @@
+package objects
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/kubeclient"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiffObjects(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"resourceVersion": "1",
+			"labels":          map[string]interface{}{"app": "web", "tier": "frontend"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.24"},
+					},
+				},
+			},
+		},
+	}}
+	updated := live.DeepCopy()
+	updated.SetResourceVersion("2")
+	updated.SetLabels(map[string]string{"app": "web", "team": "checkout"})
+	_ = unstructured.SetNestedField(updated.Object, int64(3), "spec", "replicas")
+	_ = unstructured.SetNestedSlice(updated.Object, []interface{}{
+		map[string]interface{}{"name": "web", "image": "nginx:1.25"},
+		map[string]interface{}{"name": "sidecar", "image": "envoy"},
+	}, "spec", "template", "spec", "containers")
+
+	result, err := diffObjects(live, updated)
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "metadata.labels.team", Type: ChangeAdded},
+		{Path: "metadata.labels.tier", Type: ChangeRemoved},
+		{Path: "spec.replicas", Type: ChangeChanged},
+		{Path: "spec.template.spec.containers[0].image", Type: ChangeChanged},
+		{Path: "spec.template.spec.containers[1]", Type: ChangeAdded},
+	}, result.Changes)
+	assert.Contains(t, result.Diff, "-  replicas: 1\n+  replicas: 3\n")
+	assert.NotContains(t, result.Diff, "resourceVersion")
+}
+
+func TestDiffObjects_Unchanged(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cfg", "resourceVersion": "1", "generation": int64(1)},
+	}}
+	updated := live.DeepCopy()
+	updated.SetGeneration(2)
+
+	result, err := diffObjects(live, updated)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Changes)
+	assert.Empty(t, result.Diff)
+}
+
+func TestDiff_NameMismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(kubeclient.NewPool(), nil, nil, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.POST("/:ctx/:group/:version/namespaces/:namespace/:resource/:name/diff", h.Diff)
+
+	body := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"api"}}`
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/dev/core/v1/namespaces/shop/configmaps/web/diff", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `object name \"api\" doesn't match the URL`)
+}
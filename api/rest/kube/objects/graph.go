@@ -0,0 +1,381 @@
+package objects
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	ReadinessReady    = "Ready"
+	ReadinessNotReady = "NotReady"
+	ReadinessUnknown  = "Unknown"
+
+	// graphListConcurrency bounds the parallel list calls looking for dependents
+	graphListConcurrency = 8
+	// graphListLimit is the page size of the list calls looking for dependents
+	graphListLimit = 500
+)
+
+// graphSkippedResources are never owned in practice, and can be many and
+// sensitive, they aren't listed looking for dependents.
+var graphSkippedResources = map[schema.GroupResource]bool{
+	{Resource: "secrets"}:                        true,
+	{Resource: "configmaps"}:                     true,
+	{Resource: "events"}:                         true,
+	{Group: "events.k8s.io", Resource: "events"}: true,
+}
+
+type GraphNode struct {
+	UID        types.UID `json:"uid"`
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Readiness  string    `json:"readiness"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// GraphEdge links an owner to one of its dependents.
+type GraphEdge struct {
+	Owner      types.UID `json:"owner"`
+	Dependent  types.UID `json:"dependent"`
+	Controller bool      `json:"controller"`
+}
+
+type Graph struct {
+	Root  types.UID   `json:"root"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Graph returns the ownership tree of an object: its ancestors found through
+// owner references and its descendants found by listing every listable kind
+// and matching the owner UIDs. Owners which can't be read are placeholder
+// nodes with an Unknown readiness.
+func (h *Handler) Graph(c *gin.Context) {
+	logger := getLogger(c, h, "Graph")
+
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	discoveryClient, err := kctx.DiscoveryClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes discovery client for context")
+		return
+	}
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+
+	root, err := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace")).
+		Get(c.Request.Context(), c.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes object")
+		return
+	}
+
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		api.AbortWithError(c, logger, err, "Couldn't load Kubernetes preferred resources")
+		return
+	}
+
+	b := &graphBuilder{
+		client:    client,
+		resources: listableResources(resourceLists),
+		logger:    logger,
+		graph:     Graph{Root: root.GetUID(), Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		visited:   make(map[types.UID]bool),
+	}
+	b.addNode(root)
+	b.addAncestors(c.Request.Context(), root)
+	b.addDescendants(c.Request.Context(), root)
+	c.JSON(http.StatusOK, b.graph)
+}
+
+type graphResource struct {
+	gvr        schema.GroupVersionResource
+	kind       string
+	namespaced bool
+}
+
+type graphBuilder struct {
+	client    dynamic.Interface
+	resources []graphResource
+	logger    *logrus.Entry
+	graph     Graph
+	visited   map[types.UID]bool
+}
+
+func (b *graphBuilder) addNode(obj *unstructured.Unstructured) bool {
+	if b.visited[obj.GetUID()] {
+		return false
+	}
+	b.visited[obj.GetUID()] = true
+	readiness, reason := objectReadiness(obj)
+	b.graph.Nodes = append(b.graph.Nodes, GraphNode{
+		UID:        obj.GetUID(),
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Readiness:  readiness,
+		Reason:     reason,
+	})
+	return true
+}
+
+// addPlaceholder adds a node for an owner which couldn't be read, so that
+// the edges to it still point to a node.
+func (b *graphBuilder) addPlaceholder(namespace string, ref metav1.OwnerReference, err error) {
+	b.visited[ref.UID] = true
+	if r, found := b.resourceFor(ref); found && !r.namespaced {
+		namespace = ""
+	}
+	reason := string(apierrors.ReasonForError(err))
+	if reason == "" {
+		reason = string(metav1.StatusReasonUnknown)
+	}
+	b.graph.Nodes = append(b.graph.Nodes, GraphNode{
+		UID:        ref.UID,
+		APIVersion: ref.APIVersion,
+		Kind:       ref.Kind,
+		Namespace:  namespace,
+		Name:       ref.Name,
+		Readiness:  ReadinessUnknown,
+		Reason:     reason,
+	})
+}
+
+func (b *graphBuilder) addEdge(owner metav1.OwnerReference, dependent types.UID) {
+	b.graph.Edges = append(b.graph.Edges, GraphEdge{
+		Owner:      owner.UID,
+		Dependent:  dependent,
+		Controller: owner.Controller != nil && *owner.Controller,
+	})
+}
+
+func (b *graphBuilder) addAncestors(ctx context.Context, obj *unstructured.Unstructured) {
+	for _, ref := range obj.GetOwnerReferences() {
+		if b.visited[ref.UID] {
+			b.addEdge(ref, obj.GetUID())
+			continue
+		}
+		owner, err := b.getOwner(ctx, obj.GetNamespace(), ref)
+		if err != nil {
+			b.logger.
+				WithError(err).
+				WithField("owner", ref.Kind+"/"+ref.Name).
+				Debug("Couldn't get owner of Kubernetes object")
+			b.addPlaceholder(obj.GetNamespace(), ref, err)
+			b.addEdge(ref, obj.GetUID())
+			continue
+		}
+		added := b.addNode(owner)
+		b.addEdge(ref, obj.GetUID())
+		if added {
+			b.addAncestors(ctx, owner)
+		}
+	}
+}
+
+func (b *graphBuilder) getOwner(ctx context.Context, namespace string, ref metav1.OwnerReference) (*unstructured.Unstructured, error) {
+	r, found := b.resourceFor(ref)
+	if !found {
+		return nil, api.NewNotFound("unknown owner kind " + ref.Kind)
+	}
+	// cluster scoped objects can't be owned by namespaced ones
+	if !r.namespaced {
+		namespace = ""
+	}
+	owner, err := b.client.Resource(r.gvr).Namespace(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if owner.GetUID() != ref.UID {
+		return nil, apierrors.NewNotFound(r.gvr.GroupResource(), ref.Name)
+	}
+	return owner, nil
+}
+
+func (b *graphBuilder) resourceFor(ref metav1.OwnerReference) (graphResource, bool) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return graphResource{}, false
+	}
+	for _, r := range b.resources {
+		if r.gvr.Group == gv.Group && r.kind == ref.Kind {
+			return r, true
+		}
+	}
+	return graphResource{}, false
+}
+
+func (b *graphBuilder) addDescendants(ctx context.Context, root *unstructured.Unstructured) {
+	// dependents of a namespaced object live in the same namespace
+	namespace := root.GetNamespace()
+	dependents := make(map[types.UID][]*unstructured.Unstructured)
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, graphListConcurrency)
+	for _, r := range b.resources {
+		if (namespace != "" && !r.namespaced) || graphSkippedResources[r.gvr.GroupResource()] {
+			continue
+		}
+		wg.Add(1)
+		go func(r graphResource) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			items, err := b.listAll(ctx, r.gvr, namespace)
+			if err != nil {
+				b.logger.
+					WithError(err).
+					WithField("candidate", r.gvr.String()).
+					Debug("Couldn't list dependent candidates")
+				return
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			for i := range items {
+				for _, ref := range items[i].GetOwnerReferences() {
+					dependents[ref.UID] = append(dependents[ref.UID], &items[i])
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	queue := []types.UID{root.GetUID()}
+	for len(queue) > 0 {
+		owner := queue[0]
+		queue = queue[1:]
+		children := dependents[owner]
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].GetKind()+"/"+children[i].GetName() < children[j].GetKind()+"/"+children[j].GetName()
+		})
+		for _, child := range children {
+			for _, ref := range child.GetOwnerReferences() {
+				if ref.UID == owner {
+					b.addEdge(ref, child.GetUID())
+				}
+			}
+			if b.addNode(child) {
+				queue = append(queue, child.GetUID())
+			}
+		}
+	}
+}
+
+// listAll lists every object of a resource, a page at a time.
+func (b *graphBuilder) listAll(ctx context.Context, gvr schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
+	var items []unstructured.Unstructured
+	opts := metav1.ListOptions{Limit: graphListLimit}
+	for {
+		list, err := b.client.Resource(gvr).Namespace(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+		opts.Continue = list.GetContinue()
+		if opts.Continue == "" {
+			return items, nil
+		}
+	}
+}
+
+// listableResources keeps the top level resources which support list.
+func listableResources(resourceLists []*metav1.APIResourceList) []graphResource {
+	var resources []graphResource
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !HasVerb(r.Verbs, "list") {
+				continue
+			}
+			resources = append(resources, graphResource{
+				gvr:        gv.WithResource(r.Name),
+				kind:       r.Kind,
+				namespaced: r.Namespaced,
+			})
+		}
+	}
+	return resources
+}
+
+// HasVerb tells whether a resource supports a verb.
+func HasVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// objectReadiness derives a readiness from the conventions most kinds
+// follow: a Ready or Available condition, or ready replica counts.
+func objectReadiness(obj *unstructured.Unstructured) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, conditionType := range []string{"Ready", "Available"} {
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+			reason, _ := condition["reason"].(string)
+			switch condition["status"] {
+			case "True":
+				return ReadinessReady, reason
+			case "False":
+				return ReadinessNotReady, reason
+			default:
+				return ReadinessUnknown, reason
+			}
+		}
+	}
+
+	if phase, found, _ := unstructured.NestedString(obj.Object, "status", "phase"); found {
+		switch phase {
+		case "Succeeded", "Active", "Bound":
+			return ReadinessReady, phase
+		case "Failed", "Lost":
+			return ReadinessNotReady, phase
+		}
+	}
+
+	desired, hasDesired, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+	if !hasDesired {
+		desired, hasDesired, _ = unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		ready, _, _ = unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	}
+	if hasDesired {
+		if ready >= desired {
+			return ReadinessReady, ""
+		}
+		return ReadinessNotReady, "ReplicasNotReady"
+	}
+	return ReadinessUnknown, ""
+}
@@ -0,0 +1,192 @@
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestObjectReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		object    map[string]interface{}
+		readiness string
+		reason    string
+	}{
+		{
+			name: "pod with ready condition",
+			object: map[string]interface{}{
+				"kind": "Pod",
+				"status": map[string]interface{}{
+					"phase": "Running",
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Ready", "status": "False", "reason": "ContainersNotReady"},
+					},
+				},
+			},
+			readiness: ReadinessNotReady,
+			reason:    "ContainersNotReady",
+		},
+		{
+			name: "deployment with available condition",
+			object: map[string]interface{}{
+				"kind": "Deployment",
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Progressing", "status": "True"},
+						map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+					},
+				},
+			},
+			readiness: ReadinessReady,
+			reason:    "MinimumReplicasAvailable",
+		},
+		{
+			name: "replica set missing replicas",
+			object: map[string]interface{}{
+				"kind":   "ReplicaSet",
+				"spec":   map[string]interface{}{"replicas": int64(3)},
+				"status": map[string]interface{}{"readyReplicas": int64(2)},
+			},
+			readiness: ReadinessNotReady,
+			reason:    "ReplicasNotReady",
+		},
+		{
+			name: "daemon set",
+			object: map[string]interface{}{
+				"kind":   "DaemonSet",
+				"status": map[string]interface{}{"desiredNumberScheduled": int64(2), "numberReady": int64(2)},
+			},
+			readiness: ReadinessReady,
+		},
+		{
+			name:      "config map",
+			object:    map[string]interface{}{"kind": "ConfigMap"},
+			readiness: ReadinessUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness, reason := objectReadiness(&unstructured.Unstructured{Object: tt.object})
+			assert.Equal(t, tt.readiness, readiness)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func graphObject(apiVersion, kind, name string, uid types.UID, owner *metav1.OwnerReference) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace("shop")
+	obj.SetName(name)
+	obj.SetUID(uid)
+	if owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return obj
+}
+
+func TestGraphBuilder(t *testing.T) {
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	replicaSets := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+	// the deployment owning the replica set is gone
+	rs := graphObject("apps/v1", "ReplicaSet", "web-5d4f", "rs", &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "deploy"})
+	pod := graphObject("v1", "Pod", "web-5d4f-x", "pod", &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d4f", UID: "rs"})
+	secret := graphObject("v1", "Secret", "web-token", "secret", &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d4f", UID: "rs"})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		deployments: "DeploymentList",
+		replicaSets: "ReplicaSetList",
+		pods:        "PodList",
+		secrets:     "SecretList",
+	}, rs, pod, secret)
+
+	b := &graphBuilder{
+		client: client,
+		resources: []graphResource{
+			{gvr: deployments, kind: "Deployment", namespaced: true},
+			{gvr: replicaSets, kind: "ReplicaSet", namespaced: true},
+			{gvr: pods, kind: "Pod", namespaced: true},
+			{gvr: secrets, kind: "Secret", namespaced: true},
+		},
+		logger:  logrus.NewEntry(logrus.New()),
+		graph:   Graph{Root: rs.GetUID(), Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		visited: make(map[types.UID]bool),
+	}
+	b.addNode(rs)
+	b.addAncestors(context.Background(), rs)
+	b.addDescendants(context.Background(), rs)
+
+	assert.Equal(t, []GraphNode{
+		{UID: "rs", APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "shop", Name: "web-5d4f", Readiness: ReadinessUnknown},
+		{UID: "deploy", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "shop", Name: "web", Readiness: ReadinessUnknown, Reason: "NotFound"},
+		{UID: "pod", APIVersion: "v1", Kind: "Pod", Namespace: "shop", Name: "web-5d4f-x", Readiness: ReadinessUnknown},
+	}, b.graph.Nodes)
+	assert.Equal(t, []GraphEdge{
+		{Owner: "deploy", Dependent: "rs"},
+		{Owner: "rs", Dependent: "pod"},
+	}, b.graph.Edges)
+}
+
+func TestGraphBuilder_Pages(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	rs := graphObject("apps/v1", "ReplicaSet", "web-5d4f", "rs", nil)
+
+	// every pod is owned by the replica set, the last page holds the last one
+	total := graphListLimit + 1
+	var continues []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		continues = append(continues, r.URL.Query().Get("continue"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+		end := start + graphListLimit
+		next := strconv.Itoa(end)
+		if end >= total {
+			end, next = total, ""
+		}
+		items := []interface{}{}
+		for i := start; i < end; i++ {
+			pod := graphObject("v1", "Pod", "web-5d4f-"+strconv.Itoa(i), types.UID("pod-"+strconv.Itoa(i)),
+				&metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d4f", UID: "rs"})
+			items = append(items, pod.Object)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "PodList",
+			"metadata":   map[string]interface{}{"continue": next},
+			"items":      items,
+		})
+	}))
+	defer server.Close()
+	client, err := dynamic.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	b := &graphBuilder{
+		client:    client,
+		resources: []graphResource{{gvr: pods, kind: "Pod", namespaced: true}},
+		logger:    logrus.NewEntry(logrus.New()),
+		graph:     Graph{Root: rs.GetUID(), Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		visited:   make(map[types.UID]bool),
+	}
+	b.addNode(rs)
+	b.addDescendants(context.Background(), rs)
+
+	assert.Equal(t, []string{"", strconv.Itoa(graphListLimit)}, continues)
+	assert.Len(t, b.graph.Nodes, total+1)
+	assert.Len(t, b.graph.Edges, total)
+}
@@ -0,0 +1,150 @@
+package objects
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/history"
+	"k8s-explore/logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"net/http"
+)
+
+// History lists the recorded revisions of an object, newest first.
+func (h *Handler) History(c *gin.Context) {
+	logger := getLogger(c, h, "History")
+	if _, err := h.clientPool.Context(c.Param("ctx")); err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	revisions, err := h.history.List(objectRef(c, c.Param("namespace"), c.Param("name")))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list object history")
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// Restore brings an object back to a recorded revision, deleted objects are
+// re-created.
+func (h *Handler) Restore(c *gin.Context) {
+	logger := getLogger(c, h, "Restore").WithField("revision", c.Param("revision"))
+	if _, err := h.clientPool.Context(c.Param("ctx")); err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+
+	rev, err := h.history.Get(objectRef(c, c.Param("namespace"), c.Param("name")), c.Param("revision"))
+	if err != nil {
+		if errors.Is(err, history.ErrRevisionNotFound) {
+			err = api.NewNotFound("revision " + c.Param("revision") + " not found")
+		}
+		api.AbortWithError(c, logger, err, "Couldn't get object revision")
+		return
+	}
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	obj := withoutServerMetadata(rev.Object)
+	live, err := resource.Get(c.Request.Context(), c.Param("name"), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj, err = resource.Create(c.Request.Context(), obj, metav1.CreateOptions{})
+		if err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't re-create Kubernetes object")
+			return
+		}
+		c.JSON(http.StatusCreated, obj)
+		return
+	}
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes object")
+		return
+	}
+
+	obj.SetResourceVersion(live.GetResourceVersion())
+	obj, err = resource.Update(c.Request.Context(), obj, metav1.UpdateOptions{})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't restore Kubernetes object")
+		return
+	}
+	h.recordRevision(c, logger, live, history.OperationRestore)
+	c.JSON(http.StatusOK, obj)
+}
+
+// liveObject fetches the object about to be mutated so that its state can
+// be recorded once the mutation succeeded.
+func (h *Handler) liveObject(c *gin.Context, logger *logrus.Entry, resource dynamic.ResourceInterface, name string) *unstructured.Unstructured {
+	live, err := resource.Get(c.Request.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.
+				WithError(err).
+				Warn("Couldn't get Kubernetes object for the history")
+		}
+		return nil
+	}
+	return live
+}
+
+// recordRevision stores the pre-mutation state of an object, failing to do
+// so doesn't fail the mutation which already happened.
+func (h *Handler) recordRevision(c *gin.Context, logger *logrus.Entry, live *unstructured.Unstructured, operation string) {
+	if live == nil {
+		return
+	}
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		return
+	}
+	rev, err := h.history.Record(objectRef(c, live.GetNamespace(), live.GetName()), history.Revision{
+		RequestID:  logging.RequestID(c.Request.Context()),
+		Context:    kctx.Name(),
+		ClusterUID: kctx.ClusterUID(),
+		Operation:  operation,
+		Object:     live,
+	})
+	if err != nil {
+		logger.
+			WithError(err).
+			Error("Couldn't record object history")
+		return
+	}
+	logger.
+		WithField("revision", rev.ID).
+		Debug("Recorded object history")
+}
+
+func objectRef(c *gin.Context, namespace string, name string) history.ObjectRef {
+	return history.ObjectRef{
+		Context:   c.Param("ctx"),
+		Resource:  groupVersionResource(c),
+		Namespace: namespace,
+		Name:      name,
+	}
+}
+
+// withoutServerMetadata drops the fields populated by the server which
+// prevent an object from being written back.
+func withoutServerMetadata(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetDeletionTimestamp(nil)
+	obj.SetDeletionGracePeriodSeconds(nil)
+	obj.SetManagedFields(nil)
+	obj.SetSelfLink("")
+	unstructured.RemoveNestedField(obj.Object, "status")
+	return obj
+}
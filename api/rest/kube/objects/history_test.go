@@ -0,0 +1,31 @@
+package objects
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/history"
+	"k8s-explore/kubeclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHistory_UnknownContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := history.NewStore(t.TempDir())
+	assert.NoError(t, err)
+	h := NewHandler(kubeclient.NewPool(), store, nil, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.GET("/:ctx/:group/:version/namespaces/:namespace/:resource/:name/history", h.History)
+	router.POST("/:ctx/:group/:version/namespaces/:namespace/:resource/:name/history/:revision/restore", h.Restore)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/gone/apps/v1/namespaces/shop/deployments/web/history", nil),
+		httptest.NewRequest(http.MethodPost, "/gone/apps/v1/namespaces/shop/deployments/web/history/1/restore", nil),
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, req.URL.Path)
+	}
+}
@@ -1,77 +1,139 @@
 package objects
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"k8s-explore/api"
+	"k8s-explore/history"
 	"k8s-explore/kubeclient"
+	"k8s-explore/openapi"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
 	"net/http"
+	"strconv"
 )
 
+// DefaultFieldManager manages the fields of the writes which don't name a
+// field manager.
+const DefaultFieldManager = "kexp"
+
+// HeaderPatchType reports the patch type the server finally used.
+const HeaderPatchType = "X-Patch-Type"
+
+const (
+	subresourceStatus = "status"
+	subresourceScale  = "scale"
+)
+
+// FieldOwner describes a manager owning a set of fields of an object.
+type FieldOwner struct {
+	Manager     string          `json:"manager"`
+	Operation   string          `json:"operation"`
+	APIVersion  string          `json:"apiVersion"`
+	Subresource string          `json:"subresource,omitempty"`
+	Time        *metav1.Time    `json:"time,omitempty"`
+	Fields      json.RawMessage `json:"fields,omitempty"`
+}
+
+type ApplyResult struct {
+	Object        *unstructured.Unstructured `json:"object"`
+	ManagedFields []FieldOwner               `json:"managedFields"`
+}
+
+// ObjectList is a page of objects along with the list metadata needed to
+// fetch the next page or to start a watch from the list revision.
+type ObjectList struct {
+	Metadata metav1.ListMeta             `json:"metadata"`
+	Items    []unstructured.Unstructured `json:"items"`
+}
+
+// ScaleView is the normalized view of the scale subresource.
+type ScaleView struct {
+	Replicas        int64  `json:"replicas"`
+	CurrentReplicas int64  `json:"currentReplicas"`
+	Selector        string `json:"selector"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// DeleteCollectionConfirmation tells how many objects a collection delete
+// would remove.
+type DeleteCollectionConfirmation struct {
+	api.ErrorResponse
+	Matched int `json:"matched"`
+}
+
 type Handler struct {
 	api.Handler
 	clientPool *kubeclient.ClientPool
+	history    *history.Store
+	schemas    *openapi.Cache
 }
 
-func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+func NewHandler(
+	clientPool *kubeclient.ClientPool,
+	historyStore *history.Store,
+	schemas *openapi.Cache,
+	logger *logrus.Entry,
+) *Handler {
 	return &Handler{
 		Handler:    api.NewHandler("kube/objects", logger),
 		clientPool: clientPool,
+		history:    historyStore,
+		schemas:    schemas,
 	}
 }
 
 func (h *Handler) kubeClient(c *gin.Context, logger *logrus.Entry) (dynamic.Interface, error) {
 	kctx, err := h.clientPool.Context(c.Param("ctx"))
 	if err != nil {
-		logger.WithError(err).Error("unknown context")
-		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": "unknown context"})
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return nil, err
 	}
 	client, err := kctx.DynamicClient()
 	if err != nil {
-		logger.WithError(err).Error("can not get k8s client for the context")
-		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return nil, err
 	}
 	return client, nil
 }
 
 func (h *Handler) Get(c *gin.Context) {
 	logger := getLogger(c, h, "Get")
-	group := c.Param("group")
-	if group == "core" {
-		group = ""
+	subresources, err := subresourcesFromPath(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown subresource")
+		return
+	}
+	table, ok := isTableFormat(c, logger)
+	if !ok {
+		return
+	}
+	if table && len(subresources) == 0 {
+		h.table(c, logger, metav1.ListOptions{})
+		return
 	}
 	client, err := h.kubeClient(c, logger)
 	if err != nil {
 		return
 	}
-	obj, err := client.Resource(schema.GroupVersionResource{
-		Group:    group,
-		Version:  c.Param("version"),
-		Resource: c.Param("resource"),
-	}).Namespace(c.Param("namespace")).Get(c.Request.Context(), c.Param("name"), metav1.GetOptions{})
+	obj, err := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace")).
+		Get(c.Request.Context(), c.Param("name"), metav1.GetOptions{}, subresources...)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			c.AbortWithStatusJSON(
-				http.StatusNotFound,
-				map[string]string{"error": "not found"},
-			)
-			return
-		}
-
-		logger.
-			WithError(err).
-			Error("Couldn't get Kubernetes object")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes object")
+		return
+	}
+	if c.Param("subresource") == subresourceScale {
+		c.JSON(http.StatusOK, scaleView(obj))
 		return
 	}
 	c.JSON(http.StatusOK, obj)
@@ -80,9 +142,18 @@ func (h *Handler) Get(c *gin.Context) {
 func (h *Handler) List(c *gin.Context) {
 	logger := getLogger(c, h, "List")
 
-	group := c.Param("group")
-	if group == "core" {
-		group = ""
+	opts, err := listOptionsFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid list options")
+		return
+	}
+	table, ok := isTableFormat(c, logger)
+	if !ok {
+		return
+	}
+	if table {
+		h.table(c, logger, opts)
+		return
 	}
 
 	client, err := h.kubeClient(c, logger)
@@ -91,74 +162,289 @@ func (h *Handler) List(c *gin.Context) {
 	}
 
 	list, err := client.
-		Resource(schema.GroupVersionResource{
-			Group:    group,
-			Version:  c.Param("version"),
-			Resource: c.Param("resource"),
-		}).
+		Resource(groupVersionResource(c)).
 		Namespace(c.Param("namespace")).
-		List(c.Request.Context(), metav1.ListOptions{
-			FieldSelector: c.Query("fieldSelector"),
-			LabelSelector: c.Query("labelSelector"),
-		})
+		List(c.Request.Context(), opts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list Kubernetes objects")
+		return
+	}
+
+	if list.Items == nil {
+		list.Items = []unstructured.Unstructured{}
+	}
+	c.JSON(http.StatusOK, ObjectList{
+		Metadata: metav1.ListMeta{
+			ResourceVersion:    list.GetResourceVersion(),
+			Continue:           list.GetContinue(),
+			RemainingItemCount: list.GetRemainingItemCount(),
+		},
+		Items: list.Items,
+	})
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	logger := getLogger(c, h, "Create")
+	gvr := groupVersionResource(c)
+	fieldValidation, err := fieldValidationFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid create options")
+		return
+	}
+
+	obj, err := h.unstructuredObjectFromRequest(c, logger)
+	if err != nil {
+		return
+	}
+
+	namespace := c.Param("namespace")
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	if obj.GetNamespace() != namespace {
+		err := api.NewBadRequest(fmt.Sprintf("object namespace %q doesn't match the URL", obj.GetNamespace()))
+		api.AbortWithError(c, logger, err, "Object namespace doesn't match the URL")
+		return
+	}
+
+	kind, err := h.kindFor(c, gvr)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't resolve kind for the resource")
+		return
+	}
+	if obj.GetKind() != kind {
+		err := api.NewBadRequest(fmt.Sprintf("object kind %q doesn't match the URL, expected %q", obj.GetKind(), kind))
+		api.AbortWithError(c, logger, err, "Object kind doesn't match the URL")
+		return
+	}
+	if err := h.validateObject(c, logger, obj, fieldValidation); err != nil {
+		return
+	}
+
+	client, err := h.kubeClient(c, logger)
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't list Kubernetes objects")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
 		return
 	}
 
-	c.JSON(http.StatusOK, list.Items)
+	obj, err = client.
+		Resource(gvr).
+		Namespace(namespace).
+		Create(c.Request.Context(), obj, metav1.CreateOptions{FieldValidation: fieldValidation})
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't create Kubernetes object")
+		return
+	}
+
+	c.JSON(http.StatusCreated, obj)
 }
 
 func (h *Handler) Update(c *gin.Context) {
 	logger := getLogger(c, h, "Update")
-	group := c.Param("group")
-	if group == "core" {
-		group = ""
+	subresources, err := subresourcesFromPath(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown subresource")
+		return
+	}
+	fieldValidation, err := fieldValidationFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid update options")
+		return
 	}
 
 	obj, err := h.unstructuredObjectFromRequest(c, logger)
 	if err != nil {
 		return
 	}
+	// subresources have schemas of their own, e.g. autoscaling/v1 Scale
+	if len(subresources) == 0 {
+		if err := h.validateObject(c, logger, obj, fieldValidation); err != nil {
+			return
+		}
+	}
 
 	client, err := h.kubeClient(c, logger)
 	if err != nil {
 		return
 	}
 
-	obj, err = client.
-		Resource(schema.GroupVersionResource{
-			Group:    group,
-			Version:  c.Param("version"),
-			Resource: c.Param("resource"),
-		}).
-		Namespace(c.Param("namespace")).
-		Update(c.Request.Context(), obj, metav1.UpdateOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		logger.
-			WithError(err).
-			Error("Couldn't update Kubernetes object")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	live := h.liveObject(c, logger, resource, c.Param("name"))
+	obj, err = resource.Update(c.Request.Context(), obj, metav1.UpdateOptions{FieldValidation: fieldValidation}, subresources...)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't update Kubernetes object")
+		return
+	}
+	h.recordRevision(c, logger, live, history.OperationUpdate)
+
+	c.JSON(http.StatusOK, obj)
+}
+
+// Patch applies a partial change to an object, the patch type is chosen
+// from the request content type. A patch isn't an object, it isn't
+// validated against the schema here: the API server validates the result,
+// fieldValidation tells it how to treat unknown fields.
+func (h *Handler) Patch(c *gin.Context) {
+	logger := getLogger(c, h, "Patch")
+	subresources, err := subresourcesFromPath(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown subresource")
+		return
+	}
+
+	var patchType types.PatchType
+	switch contentType := c.ContentType(); contentType {
+	case string(types.ApplyPatchType):
+		h.Apply(c)
+		return
+	case string(types.JSONPatchType), string(types.MergePatchType), string(types.StrategicMergePatchType):
+		patchType = types.PatchType(contentType)
+	default:
+		err := api.NewUnsupportedMediaType(fmt.Sprintf("unsupported patch content type %q", contentType))
+		api.AbortWithError(c, logger, err, "Unsupported patch content type")
+		return
+	}
+
+	dryRun, err := dryRunFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid patch options")
+		return
+	}
+	fieldValidation, err := fieldValidationFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid patch options")
+		return
+	}
+	opts := metav1.PatchOptions{
+		DryRun:          dryRun,
+		FieldManager:    c.DefaultQuery("fieldManager", DefaultFieldManager),
+		FieldValidation: fieldValidation,
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't read request body")
+		return
+	}
+	patch, err := yaml.ToJSON(body)
+	if err != nil {
+		api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("malformed patch: %v", err)), "Couldn't convert patch to JSON")
+		return
+	}
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	var live *unstructured.Unstructured
+	if len(opts.DryRun) == 0 {
+		live = h.liveObject(c, logger, resource, c.Param("name"))
+	}
+	obj, err := resource.Patch(c.Request.Context(), c.Param("name"), patchType, patch, opts, subresources...)
+	if patchType == types.StrategicMergePatchType && apierrors.IsUnsupportedMediaType(err) {
+		// custom resources don't support strategic merge, a merge patch is the closest equivalent
+		logger.Debug("Strategic merge patch isn't supported, falling back to merge patch")
+		patchType = types.MergePatchType
+		obj, err = resource.Patch(c.Request.Context(), c.Param("name"), patchType, patch, opts, subresources...)
+	}
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't patch Kubernetes object")
 		return
 	}
+	h.recordRevision(c, logger, live, history.OperationUpdate)
 
+	c.Header(HeaderPatchType, string(patchType))
 	c.JSON(http.StatusOK, obj)
 }
 
+// Apply performs a server-side apply of the object in the request body. The
+// object is partial, it only holds the fields the manager owns, it isn't
+// validated against the schema here: the API server validates the result
+// and always rejects unknown fields of an apply.
+func (h *Handler) Apply(c *gin.Context) {
+	logger := getLogger(c, h, "Apply")
+	subresources, err := subresourcesFromPath(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown subresource")
+		return
+	}
+
+	opts, err := ApplyOptionsFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid apply options")
+		return
+	}
+
+	obj, err := h.unstructuredObjectFromRequest(c, logger)
+	if err != nil {
+		return
+	}
+	if obj.GetName() != "" && obj.GetName() != c.Param("name") {
+		err := api.NewBadRequest(fmt.Sprintf("object name %q doesn't match the URL", obj.GetName()))
+		api.AbortWithError(c, logger, err, "Object name doesn't match the URL")
+		return
+	}
+	obj.SetName(c.Param("name"))
+	namespace := c.Param("namespace")
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	if obj.GetNamespace() != namespace {
+		err := api.NewBadRequest(fmt.Sprintf("object namespace %q doesn't match the URL", obj.GetNamespace()))
+		api.AbortWithError(c, logger, err, "Object namespace doesn't match the URL")
+		return
+	}
+	// managed fields are owned by the server and must not be sent with an apply
+	obj.SetManagedFields(nil)
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(namespace)
+
+	var live *unstructured.Unstructured
+	if len(opts.DryRun) == 0 {
+		live = h.liveObject(c, logger, resource, c.Param("name"))
+	}
+	obj, err = resource.Apply(c.Request.Context(), c.Param("name"), obj, opts, subresources...)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't apply Kubernetes object")
+		return
+	}
+	h.recordRevision(c, logger, live, history.OperationUpdate)
+
+	c.JSON(http.StatusOK, ApplyResult{
+		Object:        obj,
+		ManagedFields: fieldOwners(obj),
+	})
+}
+
 func (h *Handler) Delete(c *gin.Context) {
 	logger := getLogger(c, h, "Delete")
-	group := c.Param("group")
-	if group == "core" {
-		group = ""
+
+	opts, err := deleteOptionsFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid delete options")
+		return
+	}
+	if uid := c.Query("uid"); uid != "" {
+		opts.Preconditions = &metav1.Preconditions{UID: (*types.UID)(&uid)}
+	}
+	if resourceVersion := c.Query("resourceVersion"); resourceVersion != "" {
+		if opts.Preconditions == nil {
+			opts.Preconditions = &metav1.Preconditions{}
+		}
+		opts.Preconditions.ResourceVersion = &resourceVersion
 	}
 
 	client, err := h.kubeClient(c, logger)
@@ -166,24 +452,81 @@ func (h *Handler) Delete(c *gin.Context) {
 		return
 	}
 
-	err = client.
-		Resource(schema.GroupVersionResource{
-			Group:    group,
-			Version:  c.Param("version"),
-			Resource: c.Param("resource"),
-		}).
-		Namespace(c.Param("namespace")).
-		Delete(c.Request.Context(), c.Param("name"), metav1.DeleteOptions{})
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	var live *unstructured.Unstructured
+	if len(opts.DryRun) == 0 {
+		live = h.liveObject(c, logger, resource, c.Param("name"))
+	}
+	err = resource.Delete(c.Request.Context(), c.Param("name"), opts)
 	if err != nil && !apierrors.IsNotFound(err) {
+		api.AbortWithError(c, logger, err, "Couldn't delete Kubernetes object")
+		return
+	}
+	if err == nil {
+		h.recordRevision(c, logger, live, history.OperationDelete)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// DeleteCollection deletes every object matching the label and field
+// selectors. As a safeguard the caller has to confirm the deletion by echoing
+// the number of matched objects in the confirm parameter, the number is
+// returned with a 412 response when it is missing or outdated.
+func (h *Handler) DeleteCollection(c *gin.Context) {
+	logger := getLogger(c, h, "DeleteCollection")
+
+	opts, err := deleteOptionsFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid delete options")
+		return
+	}
+	listOpts := metav1.ListOptions{
+		FieldSelector: c.Query("fieldSelector"),
+		LabelSelector: c.Query("labelSelector"),
+	}
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	resource := client.
+		Resource(groupVersionResource(c)).
+		Namespace(c.Param("namespace"))
+
+	list, err := resource.List(c.Request.Context(), listOpts)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list Kubernetes objects")
+		return
+	}
+	matched := len(list.Items)
+	if c.Query("confirm") != strconv.Itoa(matched) {
 		logger.
-			WithError(err).
-			Error("Couldn't delete Kubernetes object")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+			WithField("matched", matched).
+			WithField("confirm", c.Query("confirm")).
+			Info("Collection delete hasn't been confirmed")
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, DeleteCollectionConfirmation{
+			ErrorResponse: api.ErrorResponse{
+				Error:   "confirmation required",
+				Message: fmt.Sprintf("%d objects match, repeat the request with confirm=%d", matched, matched),
+			},
+			Matched: matched,
+		})
+		return
+	}
+
+	if err := resource.DeleteCollection(c.Request.Context(), opts, listOpts); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't delete Kubernetes objects")
 		return
 	}
+	if len(opts.DryRun) == 0 {
+		for i := range list.Items {
+			h.recordRevision(c, logger, &list.Items[i], history.OperationDelete)
+		}
+	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -191,52 +534,211 @@ func (h *Handler) Delete(c *gin.Context) {
 func (h *Handler) unstructuredObjectFromRequest(c *gin.Context, logger *logrus.Entry) (*unstructured.Unstructured, error) {
 	body, err := c.GetRawData()
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't read request body")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		api.AbortWithError(c, logger, err, "Couldn't read request body")
 		return nil, err
 	}
 	logger = logger.WithField("body", body)
 	jsonBody, err := yaml.ToJSON(body)
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't convert YAML to JSON")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		err = api.NewBadRequest(fmt.Sprintf("malformed YAML: %v", err))
+		api.AbortWithError(c, logger, err, "Couldn't convert YAML to JSON")
 		return nil, err
 	}
 	obj, err := runtime.Decode(unstructured.UnstructuredJSONScheme, jsonBody)
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't decode Kubernetes object")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		err = api.NewBadRequest(fmt.Sprintf("malformed Kubernetes object: %v", err))
+		api.AbortWithError(c, logger, err, "Couldn't decode Kubernetes object")
 		return nil, err
 	}
 	unstructedObject, ok := obj.(*unstructured.Unstructured)
 	if !ok {
-		logger.
-			WithField("object", obj).
-			Error("Couldn't type cast runtime.Object to unstructured.Unstructured")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		err = api.NewBadRequest("expected a single Kubernetes object")
+		api.AbortWithError(c, logger.WithField("object", obj), err, "Couldn't type cast runtime.Object to unstructured.Unstructured")
 		return nil, err
 	}
 	return unstructedObject, nil
 }
 
+func deleteOptionsFromQuery(c *gin.Context) (metav1.DeleteOptions, error) {
+	opts := metav1.DeleteOptions{}
+	switch policy := metav1.DeletionPropagation(c.Query("propagationPolicy")); policy {
+	case "":
+	case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+		opts.PropagationPolicy = &policy
+	default:
+		return opts, api.NewBadRequest(fmt.Sprintf("invalid propagationPolicy value %q", policy))
+	}
+	if gracePeriod := c.Query("gracePeriodSeconds"); gracePeriod != "" {
+		g, err := strconv.ParseInt(gracePeriod, 10, 64)
+		if err != nil || g < 0 {
+			return opts, api.NewBadRequest(fmt.Sprintf("invalid gracePeriodSeconds value %q", gracePeriod))
+		}
+		opts.GracePeriodSeconds = &g
+	}
+	dryRun, err := dryRunFromQuery(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.DryRun = dryRun
+	return opts, nil
+}
+
+func listOptionsFromQuery(c *gin.Context) (metav1.ListOptions, error) {
+	opts := metav1.ListOptions{
+		FieldSelector:        c.Query("fieldSelector"),
+		LabelSelector:        c.Query("labelSelector"),
+		Continue:             c.Query("continue"),
+		ResourceVersion:      c.Query("resourceVersion"),
+		ResourceVersionMatch: metav1.ResourceVersionMatch(c.Query("resourceVersionMatch")),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || l < 0 {
+			return opts, api.NewBadRequest(fmt.Sprintf("invalid limit value %q", limit))
+		}
+		opts.Limit = l
+	}
+	switch opts.ResourceVersionMatch {
+	case "", metav1.ResourceVersionMatchExact, metav1.ResourceVersionMatchNotOlderThan:
+	default:
+		return opts, api.NewBadRequest(fmt.Sprintf("invalid resourceVersionMatch value %q", opts.ResourceVersionMatch))
+	}
+	return opts, nil
+}
+
+// ApplyOptionsFromQuery reads the fieldManager, force and dryRun options of
+// a server-side apply.
+func ApplyOptionsFromQuery(c *gin.Context) (metav1.ApplyOptions, error) {
+	opts := metav1.ApplyOptions{
+		FieldManager: c.DefaultQuery("fieldManager", DefaultFieldManager),
+	}
+	if force := c.Query("force"); force != "" {
+		f, err := strconv.ParseBool(force)
+		if err != nil {
+			return opts, api.NewBadRequest(fmt.Sprintf("invalid force value %q", force))
+		}
+		opts.Force = f
+	}
+	dryRun, err := dryRunFromQuery(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.DryRun = dryRun
+	return opts, nil
+}
+
+func dryRunFromQuery(c *gin.Context) ([]string, error) {
+	switch dryRun := c.Query("dryRun"); dryRun {
+	case "":
+		return nil, nil
+	case metav1.DryRunAll:
+		return []string{metav1.DryRunAll}, nil
+	default:
+		return nil, api.NewBadRequest(fmt.Sprintf("invalid dryRun value %q, only %q is supported", dryRun, metav1.DryRunAll))
+	}
+}
+
+func fieldOwners(obj *unstructured.Unstructured) []FieldOwner {
+	owners := []FieldOwner{}
+	for _, entry := range obj.GetManagedFields() {
+		owner := FieldOwner{
+			Manager:     entry.Manager,
+			Operation:   string(entry.Operation),
+			APIVersion:  entry.APIVersion,
+			Subresource: entry.Subresource,
+			Time:        entry.Time,
+		}
+		if entry.FieldsV1 != nil {
+			owner.Fields = entry.FieldsV1.Raw
+		}
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// subresourcesFromPath returns the subresource addressed by the request path,
+// if any, as expected by the dynamic client.
+func subresourcesFromPath(c *gin.Context) ([]string, error) {
+	switch subresource := c.Param("subresource"); subresource {
+	case "":
+		return nil, nil
+	case subresourceStatus, subresourceScale:
+		return []string{subresource}, nil
+	default:
+		return nil, api.NewNotFound(fmt.Sprintf("unknown subresource %q", subresource))
+	}
+}
+
+func scaleView(scale *unstructured.Unstructured) ScaleView {
+	replicas, _, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	currentReplicas, _, _ := unstructured.NestedInt64(scale.Object, "status", "replicas")
+	selector, _, _ := unstructured.NestedString(scale.Object, "status", "selector")
+	return ScaleView{
+		Replicas:        replicas,
+		CurrentReplicas: currentReplicas,
+		Selector:        selector,
+		ResourceVersion: scale.GetResourceVersion(),
+	}
+}
+
+// groupVersionResource builds the resource addressed by the request path,
+// the "core" group stands for the legacy API group.
+func groupVersionResource(c *gin.Context) schema.GroupVersionResource {
+	group := c.Param("group")
+	if group == "core" {
+		group = ""
+	}
+	return schema.GroupVersionResource{
+		Group:    group,
+		Version:  c.Param("version"),
+		Resource: c.Param("resource"),
+	}
+}
+
+// NamespaceObject serves a namespace, or a subresource of it, on a path of
+// the namespaced objects: the routes of the namespaced objects would win over
+// the ones of the namespaces resource, which is cluster scoped.
+func NamespaceObject(handler gin.HandlerFunc, subresource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params := gin.Params{
+			{Key: "resource", Value: "namespaces"},
+			{Key: "name", Value: c.Param("namespace")},
+		}
+		if subresource != "" {
+			params = append(params, gin.Param{Key: "subresource", Value: subresource})
+		}
+		for _, p := range c.Params {
+			if p.Key != "namespace" {
+				params = append(params, p)
+			}
+		}
+		c.Params = params
+		handler(c)
+	}
+}
+
+// kindFor resolves the kind served under the given resource through discovery.
+func (h *Handler) kindFor(c *gin.Context, gvr schema.GroupVersionResource) (string, error) {
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		return "", err
+	}
+	client, err := kctx.DiscoveryClient()
+	if err != nil {
+		return "", err
+	}
+	resourceList, err := client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return "", err
+	}
+	for _, r := range resourceList.APIResources {
+		if r.Name == gvr.Resource {
+			return r.Kind, nil
+		}
+	}
+	return "", api.NewNotFound(fmt.Sprintf("resource %s not found in %s", gvr.Resource, gvr.GroupVersion()))
+}
+
 func getLogger(c *gin.Context, h *Handler, methodName string) *logrus.Entry {
 	logger := h.Logger(c).
 		WithField("method", methodName).
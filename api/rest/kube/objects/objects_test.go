@@ -0,0 +1,29 @@
+package objects
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNamespaceObject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gvr, name, namespace, subresource string
+	handler := func(c *gin.Context) {
+		gvr = groupVersionResource(c).String()
+		name, namespace, subresource = c.Param("name"), c.Param("namespace"), c.Param("subresource")
+	}
+	router := gin.New()
+	router.GET("/:group/:version/namespaces/:namespace/:resource/:name/:subresource", handler)
+	router.GET("/:group/:version/namespaces/:namespace/status", NamespaceObject(handler, "status"))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/core/v1/namespaces/shop/status", nil))
+	assert.Equal(t, "/v1, Resource=namespaces", gvr)
+	assert.Equal(t, []string{"shop", "", "status"}, []string{name, namespace, subresource})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/apps/v1/namespaces/shop/deployments/web/status", nil))
+	assert.Equal(t, "apps/v1, Resource=deployments", gvr)
+	assert.Equal(t, []string{"web", "shop", "status"}, []string{name, namespace, subresource})
+}
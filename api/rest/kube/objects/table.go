@@ -0,0 +1,82 @@
+package objects
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"path"
+)
+
+const (
+	formatTable = "table"
+
+	acceptTable = "application/json;as=Table;v=v1;g=meta.k8s.io"
+)
+
+// isTableFormat tells whether the request asks for kubectl-like table output,
+// the request is aborted if the format is unknown.
+func isTableFormat(c *gin.Context, logger *logrus.Entry) (table bool, ok bool) {
+	switch format := c.Query("format"); format {
+	case "":
+		return false, true
+	case formatTable:
+		return true, true
+	default:
+		api.AbortWithError(c, logger, api.NewBadRequest("unsupported format "+format), "Invalid format")
+		return false, false
+	}
+}
+
+// table fetches the object, or the list of objects when the request path has
+// no name, as a server rendered table with the columns kubectl get shows.
+func (h *Handler) table(c *gin.Context, logger *logrus.Entry, opts metav1.ListOptions) {
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	client, err := kctx.DiscoveryClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return
+	}
+
+	request := client.RESTClient().
+		Get().
+		AbsPath(resourcePath(groupVersionResource(c), c.Param("namespace"), c.Param("name"))).
+		SetHeader("Accept", acceptTable).
+		Param("includeObject", string(metav1.IncludeMetadata))
+	if c.Param("name") == "" {
+		request = request.SpecificallyVersionedParams(&opts, scheme.ParameterCodec, schema.GroupVersion{Version: "v1"})
+	}
+	body, err := request.DoRaw(c.Request.Context())
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes objects as table")
+		return
+	}
+
+	table := metav1.Table{}
+	if err := json.Unmarshal(body, &table); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't decode Kubernetes table")
+		return
+	}
+	c.JSON(http.StatusOK, table)
+}
+
+// resourcePath builds the API server path of a resource, or of a single
+// object when name is given.
+func resourcePath(gvr schema.GroupVersionResource, namespace string, name string) string {
+	p := path.Join("/apis", gvr.Group, gvr.Version)
+	if gvr.Group == "" {
+		p = path.Join("/api", gvr.Version)
+	}
+	if namespace != "" {
+		p = path.Join(p, "namespaces", namespace)
+	}
+	return path.Join(p, gvr.Resource, name)
+}
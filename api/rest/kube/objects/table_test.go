@@ -0,0 +1,18 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"testing"
+)
+
+func TestResourcePath(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	nodes := schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+
+	assert.Equal(t, "/api/v1/pods", resourcePath(pods, "", ""))
+	assert.Equal(t, "/api/v1/namespaces/shop/pods/web-0", resourcePath(pods, "shop", "web-0"))
+	assert.Equal(t, "/apis/apps/v1/namespaces/shop/deployments", resourcePath(deployments, "shop", ""))
+	assert.Equal(t, "/api/v1/nodes/node-1", resourcePath(nodes, "", "node-1"))
+}
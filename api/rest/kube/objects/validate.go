@@ -0,0 +1,88 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/openapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+)
+
+type ValidationResult struct {
+	Valid      bool                `json:"valid"`
+	Violations []openapi.Violation `json:"violations"`
+}
+
+// Validate checks the submitted object against the OpenAPI schema of its
+// kind without talking to the API server beyond fetching the schema.
+// fieldValidation=Strict reports unknown fields.
+func (h *Handler) Validate(c *gin.Context) {
+	logger := getLogger(c, h, "Validate")
+	fieldValidation, err := fieldValidationFromQuery(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid validation options")
+		return
+	}
+	obj, err := h.unstructuredObjectFromRequest(c, logger)
+	if err != nil {
+		return
+	}
+	violations, err := h.violations(c, obj, fieldValidation == metav1.FieldValidationStrict)
+	if err != nil {
+		if errors.Is(err, openapi.ErrNotFound) {
+			err = api.NewNotFound(err.Error())
+		}
+		api.AbortWithError(c, logger, err, "Couldn't validate Kubernetes object")
+		return
+	}
+	c.JSON(http.StatusOK, ValidationResult{Valid: len(violations) == 0, Violations: violations})
+}
+
+// validateObject aborts the request when the object doesn't match its
+// schema. A missing schema doesn't prevent the write, the API server has
+// the final say anyway.
+func (h *Handler) validateObject(c *gin.Context, logger *logrus.Entry, obj *unstructured.Unstructured, fieldValidation string) error {
+	violations, err := h.violations(c, obj, fieldValidation == metav1.FieldValidationStrict)
+	if err != nil {
+		logger.
+			WithError(err).
+			Warn("Couldn't validate Kubernetes object, leaving it to the API server")
+		return nil
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	causes := make([]api.ErrorCause, 0, len(violations))
+	for _, v := range violations {
+		causes = append(causes, api.ErrorCause{Type: v.Type, Field: v.Path, Message: v.Message})
+	}
+	err = api.NewInvalid(fmt.Sprintf("%s %q doesn't match its schema", obj.GetKind(), obj.GetName()), causes)
+	api.AbortWithError(c, logger, err, "Kubernetes object failed schema validation")
+	return err
+}
+
+func (h *Handler) violations(c *gin.Context, obj *unstructured.Unstructured, strict bool) ([]openapi.Violation, error) {
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		return nil, err
+	}
+	gvk := obj.GroupVersionKind()
+	doc, err := h.schemas.Document(kctx, gvk.GroupVersion())
+	if err != nil {
+		return nil, err
+	}
+	return doc.Validate(gvk, obj.Object, strict)
+}
+
+func fieldValidationFromQuery(c *gin.Context) (string, error) {
+	switch fieldValidation := c.Query("fieldValidation"); fieldValidation {
+	case "", metav1.FieldValidationIgnore, metav1.FieldValidationWarn, metav1.FieldValidationStrict:
+		return fieldValidation, nil
+	default:
+		return "", api.NewBadRequest(fmt.Sprintf("invalid fieldValidation value %q", fieldValidation))
+	}
+}
@@ -0,0 +1,387 @@
+package pods
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"io"
+	"k8s-explore/api"
+	"k8s-explore/kubeclient"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	formatTar  = "tar"
+	formatFile = "file"
+
+	contentTypeTar  = "application/x-tar"
+	contentTypeGzip = "application/gzip"
+
+	// tarRecordSize is the size of the record GNU tar fills, it writes one
+	// empty record even when the path doesn't exist. One byte more than a
+	// record is held back before answering a download.
+	tarRecordSize = 10240
+
+	// exit code of shells and runtimes for a missing executable
+	exitCodeNotFound = 127
+)
+
+// execFunc runs a command in a container, streaming stdin and stdout.
+type execFunc func(ctx context.Context, ref containerRef, command []string, stdin io.Reader, stdout io.Writer) error
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+	exec       execFunc
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	h := &Handler{
+		Handler:    api.NewHandler("kube/pods", logger),
+		clientPool: clientPool,
+	}
+	h.exec = h.execInContainer
+	return h
+}
+
+// Download copies a file or a directory out of a container, running tar in
+// it like kubectl cp. format=file answers with the content of a single file,
+// the default is a tar archive.
+func (h *Handler) Download(c *gin.Context) {
+	logger := h.loggerFor(c, "Download")
+	format := c.DefaultQuery("format", formatTar)
+	if format != formatTar && format != formatFile {
+		api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid format value %q", format)), "Invalid download options")
+		return
+	}
+	dir, base, err := splitPath(c.Query("path"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid download options")
+		return
+	}
+	if format == formatFile && base == "." {
+		api.AbortWithError(c, logger, api.NewBadRequest("the root directory can only be downloaded as tar"), "Invalid download options")
+		return
+	}
+
+	ref := containerRefFrom(c)
+	stdout, stdoutWriter := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		err := h.exec(c.Request.Context(), ref, []string{"tar", "cf", "-", "-C", dir, base}, nil, stdoutWriter)
+		stdoutWriter.Close()
+		result <- err
+	}()
+	// stops the exec whenever the response ends early
+	defer stdout.Close()
+
+	if format == formatFile {
+		h.downloadFile(c, logger, stdout, result)
+		return
+	}
+
+	head := make([]byte, tarRecordSize+1)
+	n, err := io.ReadFull(stdout, head)
+	finished := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !finished {
+		api.AbortWithError(c, logger, err, "Couldn't copy files from container")
+		return
+	}
+	if finished {
+		// tar is done already, make sure it succeeded before answering
+		if err := <-result; err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't copy files from container")
+			return
+		}
+	}
+
+	c.Header("Content-Type", contentTypeTar)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName(base) + ".tar"}))
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.Write(head[:n]); err != nil {
+		logger.WithError(err).Warn("Couldn't write download")
+		return
+	}
+	if finished {
+		return
+	}
+	if _, err := io.Copy(c.Writer, stdout); err != nil {
+		logger.WithError(err).Warn("Couldn't write download")
+		return
+	}
+	if err := <-result; err != nil {
+		// too late to tell the client, the archive is likely incomplete
+		logger.WithError(err).Warn("Copy from container failed after the download started")
+	}
+}
+
+func (h *Handler) downloadFile(c *gin.Context, logger *logrus.Entry, stdout io.Reader, result <-chan error) {
+	tr := tar.NewReader(stdout)
+	header, err := tr.Next()
+	if err != nil {
+		// tar blocks on its output until it's read
+		io.Copy(io.Discard, stdout)
+		if execErr := <-result; execErr != nil {
+			err = execErr
+		}
+		api.AbortWithError(c, logger, err, "Couldn't copy file from container")
+		return
+	}
+	if header.Typeflag != tar.TypeReg {
+		err := api.NewBadRequest(fmt.Sprintf("%s isn't a regular file, download it as tar", c.Query("path")))
+		api.AbortWithError(c, logger, err, "Couldn't copy file from container")
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(header.Name)}))
+	c.DataFromReader(http.StatusOK, header.Size, "application/octet-stream", tr, nil)
+	// the padding and the end of the archive follow the file
+	io.Copy(io.Discard, stdout)
+	if err := <-result; err != nil {
+		logger.WithError(err).Warn("Copy from container failed after the download started")
+	}
+}
+
+// Upload extracts the request body in a directory of a container. A tar
+// archive, gzipped or not, is extracted as is. Any other body is a single
+// file named by the filename parameter, or by the file of a multipart form.
+func (h *Handler) Upload(c *gin.Context) {
+	logger := h.loggerFor(c, "Upload")
+	dir := path.Clean(c.Query("path"))
+	if c.Query("path") == "" {
+		api.AbortWithError(c, logger, api.NewBadRequest("path is required"), "Invalid upload options")
+		return
+	}
+
+	var archive io.Reader
+	switch contentType := c.ContentType(); contentType {
+	case contentTypeTar:
+		archive = c.Request.Body
+	case contentTypeGzip, "application/x-gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			api.AbortWithError(c, logger, api.NewBadRequest("malformed gzip body: "+err.Error()), "Couldn't read upload")
+			return
+		}
+		defer gz.Close()
+		archive = gz
+	case "multipart/form-data":
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			api.AbortWithError(c, logger, api.NewBadRequest("the form has no file field: "+err.Error()), "Couldn't read upload")
+			return
+		}
+		defer file.Close()
+		archive, err = singleFileArchive(header.Filename, header.Size, file)
+		if err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't read upload")
+			return
+		}
+	default:
+		filename := c.Query("filename")
+		if filename == "" || strings.Contains(filename, "/") {
+			api.AbortWithError(c, logger, api.NewBadRequest("a filename without slashes is required to upload a single file"), "Invalid upload options")
+			return
+		}
+		body, size, err := spool(c.Request.Body)
+		if err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't read upload")
+			return
+		}
+		defer body.Close()
+		archive, err = singleFileArchive(filename, size, body)
+		if err != nil {
+			api.AbortWithError(c, logger, err, "Couldn't read upload")
+			return
+		}
+	}
+
+	if err := h.exec(c.Request.Context(), containerRefFrom(c), []string{"tar", "xmf", "-", "-C", dir}, archive, io.Discard); err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't copy files to container")
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// containerRef identifies the container files are copied from or to.
+type containerRef struct {
+	context   string
+	namespace string
+	pod       string
+	container string
+}
+
+func containerRefFrom(c *gin.Context) containerRef {
+	return containerRef{
+		context:   c.Param("ctx"),
+		namespace: c.Param("namespace"),
+		pod:       c.Param("name"),
+		container: c.Query("container"),
+	}
+}
+
+// execInContainer runs a command in a container, the errors are translated
+// to tell a missing tar or path from other failures.
+func (h *Handler) execInContainer(ctx context.Context, ref containerRef, command []string, stdin io.Reader, stdout io.Writer) error {
+	kctx, err := h.clientPool.Context(ref.context)
+	if err != nil {
+		return err
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		return err
+	}
+	req := client.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Namespace(ref.namespace).
+		Name(ref.pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: ref.container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(kctx.RESTConfig(), http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	return execError(ref.container, err, stderr.String())
+}
+
+func execError(container string, err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	stderr = strings.TrimSpace(stderr)
+	if container == "" {
+		container = "default"
+	}
+	var exitErr utilexec.ExitError
+	isExitErr := errors.As(err, &exitErr)
+	if strings.Contains(err.Error(), "executable file not found") ||
+		strings.Contains(stderr, "tar: not found") ||
+		(isExitErr && exitErr.ExitStatus() == exitCodeNotFound) {
+		return api.NewBadRequest(fmt.Sprintf("the %s container has no tar executable, copying files requires tar in the image", container))
+	}
+	if !isExitErr {
+		return err
+	}
+	if strings.Contains(stderr, "No such file or directory") ||
+		strings.Contains(stderr, "Cannot stat") ||
+		strings.Contains(stderr, "can't change directory") {
+		return api.NewNotFound(stderr)
+	}
+	if stderr == "" {
+		stderr = err.Error()
+	}
+	return apierrors.NewInternalError(fmt.Errorf("tar failed: %s", stderr))
+}
+
+// splitPath splits the path to copy in the directory tar runs in and the
+// entry it archives.
+func splitPath(p string) (string, string, error) {
+	if p == "" {
+		return "", "", api.NewBadRequest("path is required")
+	}
+	p = path.Clean(p)
+	if p == "/" {
+		return "/", ".", nil
+	}
+	return path.Dir(p), path.Base(p), nil
+}
+
+func archiveName(base string) string {
+	if base == "." {
+		return "root"
+	}
+	return base
+}
+
+// singleFileArchive wraps a file in a tar archive, tar being the only way
+// in of the container.
+func singleFileArchive(name string, size int64, content io.Reader) (io.Reader, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, api.NewBadRequest(fmt.Sprintf("invalid file name %q", name))
+	}
+	r, w := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(w)
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    size,
+			ModTime: time.Now(),
+		})
+		if err == nil {
+			_, err = io.CopyN(tw, content, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+// spool stores a body of unknown size in a temporary file to size the tar
+// header. Closing the returned file deletes it.
+func spool(body io.Reader) (io.ReadCloser, int64, error) {
+	f, err := os.CreateTemp("", "kexp-upload-")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(f, body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return &tempFile{File: f}, size, nil
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+func (h *Handler) loggerFor(c *gin.Context, methodName string) *logrus.Entry {
+	return h.Logger(c).
+		WithField("method", methodName).
+		WithField("context", c.Param("ctx")).
+		WithField("namespace", c.Param("namespace")).
+		WithField("pod", c.Param("name")).
+		WithField("container", c.Query("container")).
+		WithField("path", c.Query("path"))
+}
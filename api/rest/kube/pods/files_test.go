@@ -0,0 +1,191 @@
+package pods
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"k8s-explore/api"
+	utilexec "k8s.io/client-go/util/exec"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		stderr  string
+		code    int
+		message string
+	}{
+		{
+			name:    "missing tar in the runtime",
+			err:     errors.New(`command terminated with non-zero exit code: error executing command [tar cf - -C /tmp dump], exit code 126: OCI runtime exec failed: exec failed: unable to start container process: exec: "tar": executable file not found in $PATH: unknown`),
+			code:    http.StatusBadRequest,
+			message: "the app container has no tar executable, copying files requires tar in the image",
+		},
+		{
+			name:    "missing tar in the shell",
+			err:     utilexec.CodeExitError{Err: errors.New("command terminated with non-zero exit code"), Code: 127},
+			stderr:  "sh: tar: not found\n",
+			code:    http.StatusBadRequest,
+			message: "the app container has no tar executable, copying files requires tar in the image",
+		},
+		{
+			name:    "missing path",
+			err:     utilexec.CodeExitError{Err: errors.New("command terminated with non-zero exit code"), Code: 2},
+			stderr:  "tar: dump: Cannot stat: No such file or directory\ntar: Exiting with failure status due to previous errors\n",
+			code:    http.StatusNotFound,
+			message: "tar: dump: Cannot stat: No such file or directory\ntar: Exiting with failure status due to previous errors",
+		},
+		{
+			name:    "other tar failure",
+			err:     utilexec.CodeExitError{Err: errors.New("command terminated with non-zero exit code"), Code: 2},
+			stderr:  "tar: /data: Cannot open: Permission denied",
+			code:    http.StatusInternalServerError,
+			message: "Internal error occurred: tar failed: tar: /data: Cannot open: Permission denied",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, response := api.ErrorResponseFor(execError("app", test.err, test.stderr))
+			assert.Equal(t, test.code, code)
+			assert.Equal(t, test.message, response.Message)
+		})
+	}
+
+	assert.NoError(t, execError("app", nil, ""))
+}
+
+func TestSplitPath(t *testing.T) {
+	dir, base, err := splitPath("/var/log/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/log", dir)
+	assert.Equal(t, "app", base)
+
+	dir, base, err = splitPath("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", dir)
+	assert.Equal(t, ".", base)
+
+	_, _, err = splitPath("")
+	assert.Error(t, err)
+}
+
+func TestSingleFileArchive(t *testing.T) {
+	archive, err := singleFileArchive("heap.pprof", 5, strings.NewReader("hello"))
+	assert.NoError(t, err)
+
+	tr := tar.NewReader(archive)
+	header, err := tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "heap.pprof", header.Name)
+	content, err := io.ReadAll(tr)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = singleFileArchive("../etc/passwd", 5, strings.NewReader("hello"))
+	assert.Error(t, err)
+}
+
+// fakeTar answers like tar cf in a container, the archive filled up to a
+// full record as GNU tar does.
+func fakeTar(files map[string]string, result error) execFunc {
+	return func(ctx context.Context, ref containerRef, command []string, stdin io.Reader, stdout io.Writer) error {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for name, content := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+				return err
+			}
+			if _, err := tw.Write([]byte(content)); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		archive.Write(make([]byte, tarRecordSize-archive.Len()%tarRecordSize))
+		if _, err := io.Copy(stdout, &archive); err != nil {
+			return err
+		}
+		return result
+	}
+}
+
+func TestDownload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		query  string
+		exec   execFunc
+		code   int
+		body   string
+		header string
+	}{
+		{
+			name:   "file",
+			query:  "path=/tmp/dump&format=file",
+			exec:   fakeTar(map[string]string{"dump": "hello"}, nil),
+			code:   http.StatusOK,
+			body:   "hello",
+			header: `attachment; filename=dump`,
+		},
+		{
+			name:  "missing file",
+			query: "path=/tmp/dump&format=file",
+			exec:  fakeTar(nil, api.NewNotFound("tar: dump: Cannot stat: No such file or directory")),
+			code:  http.StatusNotFound,
+		},
+		{
+			name:  "missing path",
+			query: "path=/tmp/dump",
+			exec:  fakeTar(nil, api.NewNotFound("tar: dump: Cannot stat: No such file or directory")),
+			code:  http.StatusNotFound,
+		},
+		{
+			name:   "tar",
+			query:  "path=/tmp/dump",
+			exec:   fakeTar(map[string]string{"dump": "hello"}, nil),
+			code:   http.StatusOK,
+			header: `attachment; filename=dump.tar`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &Handler{Handler: api.NewHandler("kube/pods", logrus.NewEntry(logrus.New())), exec: test.exec}
+			router := gin.New()
+			router.GET("/download", h.Download)
+
+			recorder := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/download?"+test.query, nil))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("download didn't return")
+			}
+
+			assert.Equal(t, test.code, recorder.Code)
+			if test.body != "" {
+				assert.Equal(t, test.body, recorder.Body.String())
+			}
+			if test.header != "" {
+				assert.Equal(t, test.header, recorder.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
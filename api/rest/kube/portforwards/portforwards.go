@@ -0,0 +1,84 @@
+package portforwards
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/portforward"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net/http"
+)
+
+// StartRequest is the body of a start call, port takes a number or a name.
+type StartRequest struct {
+	Namespace string             `json:"namespace"`
+	Kind      string             `json:"kind"`
+	Name      string             `json:"name"`
+	Port      intstr.IntOrString `json:"port"`
+	LocalPort int                `json:"localPort"`
+}
+
+type Handler struct {
+	api.Handler
+	manager *portforward.Manager
+}
+
+func NewHandler(manager *portforward.Manager, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler: api.NewHandler("kube/portforwards", logger),
+		manager: manager,
+	}
+}
+
+// Start forwards a local port, a random one unless given, to a service or
+// pod port of the context.
+func (h *Handler) Start(c *gin.Context) {
+	logger := h.Logger(c).WithField("method", "Start").WithField("context", c.Param("ctx"))
+	req := StartRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.AbortWithError(c, logger, api.NewBadRequest("malformed port forward request: "+err.Error()), "Couldn't decode port forward request")
+		return
+	}
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		api.AbortWithError(c, logger, api.NewBadRequest("localPort must be between 0 and 65535"), "Invalid port forward request")
+		return
+	}
+
+	forward, err := h.manager.Start(c.Request.Context(), portforward.Target{
+		Context:   c.Param("ctx"),
+		Namespace: req.Namespace,
+		Kind:      req.Kind,
+		Name:      req.Name,
+		Port:      req.Port.String(),
+	}, req.LocalPort)
+	if err != nil {
+		switch {
+		case errors.Is(err, portforward.ErrInvalidTarget):
+			err = api.NewBadRequest(err.Error())
+		case errors.Is(err, portforward.ErrNoPod):
+			err = apierrors.NewServiceUnavailable(err.Error())
+		}
+		api.AbortWithError(c, logger, err, "Couldn't start port forward")
+		return
+	}
+	c.JSON(http.StatusCreated, forward)
+}
+
+// List returns the port forwards of every context.
+func (h *Handler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, h.manager.List())
+}
+
+func (h *Handler) Stop(c *gin.Context) {
+	logger := h.Logger(c).WithField("method", "Stop").WithField("forward", c.Param("id"))
+	if err := h.manager.Stop(c.Param("id")); err != nil {
+		if errors.Is(err, portforward.ErrForwardNotFound) {
+			err = api.NewNotFound("port forward " + c.Param("id") + " not found")
+		}
+		api.AbortWithError(c, logger, err, "Couldn't stop port forward")
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,120 @@
+package releases
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/helm"
+	"k8s-explore/kubeclient"
+	"k8s.io/client-go/dynamic"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/releases", logger),
+		clientPool: clientPool,
+	}
+}
+
+// List returns the Helm releases of a namespace, or of every namespace
+// without one, at their latest revision.
+func (h *Handler) List(c *gin.Context) {
+	logger := h.loggerFor(c, "List")
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	releases, err := helm.List(c.Request.Context(), client, c.Param("namespace"), logger)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't list Helm releases")
+		return
+	}
+	c.JSON(http.StatusOK, releases)
+}
+
+// Get returns a revision of a release, the latest unless the revision
+// parameter is given, with its manifest and values. all=true merges the
+// values into the defaults of the chart.
+func (h *Handler) Get(c *gin.Context) {
+	logger := h.loggerFor(c, "Get")
+	revision := 0
+	if r := c.Query("revision"); r != "" {
+		var err error
+		if revision, err = strconv.Atoi(r); err != nil || revision < 1 {
+			api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid revision value %q", r)), "Invalid release options")
+			return
+		}
+	}
+	allValues := false
+	if a := c.Query("all"); a != "" {
+		var err error
+		if allValues, err = strconv.ParseBool(a); err != nil {
+			api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid all value %q", a)), "Invalid release options")
+			return
+		}
+	}
+
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	release, err := helm.Get(c.Request.Context(), client, c.Param("namespace"), c.Param("name"), revision, allValues, logger)
+	if err != nil {
+		api.AbortWithError(c, logger, releaseError(err), "Couldn't get Helm release")
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// History returns the revisions Helm keeps of a release, oldest first.
+func (h *Handler) History(c *gin.Context) {
+	logger := h.loggerFor(c, "History")
+	client, err := h.kubeClient(c, logger)
+	if err != nil {
+		return
+	}
+	history, err := helm.History(c.Request.Context(), client, c.Param("namespace"), c.Param("name"), logger)
+	if err != nil {
+		api.AbortWithError(c, logger, releaseError(err), "Couldn't get Helm release history")
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *Handler) kubeClient(c *gin.Context, logger *logrus.Entry) (dynamic.Interface, error) {
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return nil, err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
+		return nil, err
+	}
+	return client, nil
+}
+
+func releaseError(err error) error {
+	if errors.Is(err, helm.ErrReleaseNotFound) {
+		return api.NewNotFound(err.Error())
+	}
+	return err
+}
+
+func (h *Handler) loggerFor(c *gin.Context, methodName string) *logrus.Entry {
+	return h.Logger(c).
+		WithField("method", methodName).
+		WithField("context", c.Param("ctx")).
+		WithField("namespace", c.Param("namespace")).
+		WithField("release", c.Param("name"))
+}
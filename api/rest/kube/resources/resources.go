@@ -1,59 +1,99 @@
 package resources
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"k8s-explore/api"
 	"k8s-explore/kubeclient"
+	"k8s-explore/openapi"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
 	api.Handler
 	clientPool *kubeclient.ClientPool
+	schemas    *openapi.Cache
 }
 
-func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+func NewHandler(clientPool *kubeclient.ClientPool, schemas *openapi.Cache, logger *logrus.Entry) *Handler {
 	return &Handler{
 		Handler:    api.NewHandler("kube/resources", logger),
 		clientPool: clientPool,
+		schemas:    schemas,
 	}
 }
 
 func (h *Handler) List(c *gin.Context) {
-	logger := h.Logger(c).WithField("method", "List").WithField("context", c.Param("cxt"))
+	logger := h.Logger(c).WithField("method", "List").WithField("context", c.Param("ctx"))
 	kctx, err := h.clientPool.Context(c.Param("ctx"))
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Unknown context")
-		c.AbortWithStatusJSON(
-			http.StatusNotFound,
-			map[string]string{"error": "unknown context"},
-		)
+		api.AbortWithError(c, logger, err, "Unknown context")
 		return
 	}
 	client, err := kctx.DiscoveryClient()
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't get Kubernetes client for context")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes client for context")
 		return
 	}
 	resourceList, err := client.ServerPreferredResources()
 	if err != nil {
-		logger.
-			WithError(err).
-			Error("Couldn't load Kubernetes preferred resources")
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			map[string]string{"error": "internal server error"},
-		)
+		api.AbortWithError(c, logger, err, "Couldn't load Kubernetes preferred resources")
 		return
 	}
 	c.JSON(http.StatusOK, resourceList)
 }
+
+// Explain describes a kind, or the field of the kind given by the path
+// parameter, from the OpenAPI v3 schema of the cluster like kubectl explain.
+func (h *Handler) Explain(c *gin.Context) {
+	logger := h.Logger(c).
+		WithField("method", "Explain").
+		WithField("context", c.Param("ctx")).
+		WithField("kind", c.Param("kind")).
+		WithField("path", c.Query("path"))
+	group := c.Param("group")
+	if group == "core" {
+		group = ""
+	}
+	gvk := schema.GroupVersionKind{Group: group, Version: c.Param("version"), Kind: c.Param("kind")}
+
+	refresh := false
+	if r := c.Query("refresh"); r != "" {
+		b, err := strconv.ParseBool(r)
+		if err != nil {
+			api.AbortWithError(c, logger, api.NewBadRequest(fmt.Sprintf("invalid refresh value %q", r)), "Invalid explain options")
+			return
+		}
+		refresh = b
+	}
+
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Unknown context")
+		return
+	}
+	if refresh {
+		h.schemas.Invalidate(kctx.ClusterUID())
+	}
+	doc, err := h.schemas.Document(kctx, gvk.GroupVersion())
+	if err != nil {
+		if errors.Is(err, openapi.ErrNotFound) {
+			err = api.NewNotFound(err.Error())
+		}
+		api.AbortWithError(c, logger, err, "Couldn't get OpenAPI schema")
+		return
+	}
+	explanation, err := doc.Explain(gvk, c.Query("path"))
+	if err != nil {
+		if errors.Is(err, openapi.ErrNotFound) {
+			err = api.NewNotFound(err.Error())
+		}
+		api.AbortWithError(c, logger, err, "Couldn't explain schema")
+		return
+	}
+	c.JSON(http.StatusOK, explanation)
+}
@@ -0,0 +1,213 @@
+package rollouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api"
+	"k8s-explore/kubeclient"
+	"k8s-explore/rollout"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DiffResult is the change of the pod template between two revisions.
+type DiffResult struct {
+	From int64  `json:"from"`
+	To   int64  `json:"to"`
+	Diff string `json:"diff"`
+}
+
+type Handler struct {
+	api.Handler
+	clientPool *kubeclient.ClientPool
+}
+
+func NewHandler(clientPool *kubeclient.ClientPool, logger *logrus.Entry) *Handler {
+	return &Handler{
+		Handler:    api.NewHandler("kube/rollouts", logger),
+		clientPool: clientPool,
+	}
+}
+
+// Restart rolls out the pods of the workload again.
+func (h *Handler) Restart(c *gin.Context) {
+	h.mutate(c, "Restart", (*rollout.Workload).Restart)
+}
+
+func (h *Handler) Pause(c *gin.Context) {
+	h.mutate(c, "Pause", (*rollout.Workload).Pause)
+}
+
+func (h *Handler) Resume(c *gin.Context) {
+	h.mutate(c, "Resume", (*rollout.Workload).Resume)
+}
+
+// Undo rolls the workload back to the revision parameter, or to the
+// previous revision without one.
+func (h *Handler) Undo(c *gin.Context) {
+	revision, err := revisionFromQuery(c, "revision")
+	if err != nil {
+		api.AbortWithError(c, h.loggerFor(c, "Undo"), err, "Invalid undo options")
+		return
+	}
+	h.mutate(c, "Undo", func(w *rollout.Workload, ctx context.Context) (*unstructured.Unstructured, error) {
+		return w.Undo(ctx, revision)
+	})
+}
+
+func (h *Handler) mutate(c *gin.Context, methodName string, operation func(*rollout.Workload, context.Context) (*unstructured.Unstructured, error)) {
+	logger := h.loggerFor(c, methodName)
+	workload, err := h.workload(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid workload")
+		return
+	}
+	obj, err := operation(workload, c.Request.Context())
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't "+strings.ToLower(methodName)+" rollout")
+		return
+	}
+	c.JSON(http.StatusOK, obj)
+}
+
+// History returns the revisions of the workload, oldest first.
+func (h *Handler) History(c *gin.Context) {
+	logger := h.loggerFor(c, "History")
+	workload, err := h.workload(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid workload")
+		return
+	}
+	revisions, err := workload.History(c.Request.Context())
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't get rollout history")
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// Diff compares the pod templates of the from and to revisions. To defaults
+// to the current revision, from to the revision before to.
+func (h *Handler) Diff(c *gin.Context) {
+	logger := h.loggerFor(c, "Diff")
+	to, err := revisionFromQuery(c, "to")
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid diff options")
+		return
+	}
+	from, err := revisionFromQuery(c, "from")
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid diff options")
+		return
+	}
+	workload, err := h.workload(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid workload")
+		return
+	}
+	revisions, err := workload.History(c.Request.Context())
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't get rollout history")
+		return
+	}
+
+	toRevision, err := rollout.Find(revisions, to)
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't diff revisions")
+		return
+	}
+	if from == 0 {
+		if from, err = rollout.Previous(revisions, toRevision.Revision); err != nil {
+			api.AbortWithError(c, logger, rolloutError(err), "Couldn't diff revisions")
+			return
+		}
+	}
+	fromRevision, err := rollout.Find(revisions, from)
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't diff revisions")
+		return
+	}
+	diff, err := rollout.Diff(fromRevision, toRevision)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't diff revisions")
+		return
+	}
+	c.JSON(http.StatusOK, DiffResult{From: fromRevision.Revision, To: toRevision.Revision, Diff: diff})
+}
+
+// Status returns the progress of the rollout at the moment, the
+// rollouts.status call follows it.
+func (h *Handler) Status(c *gin.Context) {
+	logger := h.loggerFor(c, "Status")
+	workload, err := h.workload(c)
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Invalid workload")
+		return
+	}
+	obj, err := workload.Get(c.Request.Context())
+	if err != nil {
+		api.AbortWithError(c, logger, err, "Couldn't get Kubernetes object")
+		return
+	}
+	status, err := rollout.StatusOf(obj)
+	if err != nil {
+		api.AbortWithError(c, logger, rolloutError(err), "Couldn't get rollout status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *Handler) workload(c *gin.Context) (*rollout.Workload, error) {
+	kctx, err := h.clientPool.Context(c.Param("ctx"))
+	if err != nil {
+		return nil, err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	workload, err := rollout.NewWorkload(client, c.Param("resource"), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		return nil, rolloutError(err)
+	}
+	return workload, nil
+}
+
+// rolloutError maps the errors of the rollout package to client errors.
+func rolloutError(err error) error {
+	switch {
+	case errors.Is(err, rollout.ErrRevisionNotFound):
+		return api.NewNotFound(err.Error())
+	case errors.Is(err, rollout.ErrUnsupportedResource),
+		errors.Is(err, rollout.ErrNotSupported),
+		errors.Is(err, rollout.ErrPaused):
+		return api.NewBadRequest(err.Error())
+	}
+	return err
+}
+
+func revisionFromQuery(c *gin.Context, name string) (int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, api.NewBadRequest(fmt.Sprintf("invalid %s value %q", name, value))
+	}
+	return revision, nil
+}
+
+func (h *Handler) loggerFor(c *gin.Context, methodName string) *logrus.Entry {
+	return h.Logger(c).
+		WithField("method", methodName).
+		WithField("context", c.Param("ctx")).
+		WithField("namespace", c.Param("namespace")).
+		WithField("resource", c.Param("resource")).
+		WithField("name", c.Param("name"))
+}
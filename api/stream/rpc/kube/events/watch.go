@@ -0,0 +1,333 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api/stream"
+	"k8s-explore/api/stream/rpc"
+	"k8s-explore/kubeclient"
+	"k8s-explore/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"sync"
+	"time"
+)
+
+const Watch rpc.CallMethod = "events.watch"
+
+var (
+	coreEvents   = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+	eventsEvents = schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"}
+)
+
+// eventSource is an events API, with the field selecting the events of an
+// object by UID.
+type eventSource struct {
+	gvr   schema.GroupVersionResource
+	field string
+}
+
+type paramsWatch struct {
+	Context   string `json:"context"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Event is an occurrence, possibly repeated, of an event of the object.
+type Event struct {
+	Type           string    `json:"type"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Source         string    `json:"source,omitempty"`
+	Count          int64     `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+}
+
+// EventList is sent whenever the events of the object change, sorted by
+// last timestamp, oldest first.
+type EventList struct {
+	UID    types.UID `json:"uid"`
+	Events []Event   `json:"events"`
+}
+
+type WatchHandler struct {
+	clientPool *kubeclient.ClientPool
+	logger     *logrus.Entry
+}
+
+func NewWatchHandler(clientPool *kubeclient.ClientPool) *WatchHandler {
+	return &WatchHandler{
+		clientPool: clientPool,
+		logger:     logrus.WithField("handler", "stream/rpc/kube/events/watch"),
+	}
+}
+
+// Handle streams the events of an object, from both the core and the
+// events.k8s.io APIs. Both serve the same events, they are told apart by
+// UID, then repeated events are merged.
+func (h *WatchHandler) Handle(ctx context.Context, call rpc.Call, reply chan<- stream.Message) error {
+	if call.Method != Watch {
+		return errors.New("call has been miss dispatched")
+	}
+	logger := logging.WithRequestID(ctx, h.logger).
+		WithField("callId", call.ID).
+		WithField("callMethod", call.Method)
+
+	params := paramsWatch{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		logger.
+			WithError(err).
+			Warn("couldn't decode call params")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	if params.Group == "core" {
+		params.Group = ""
+	}
+
+	logger = logger.WithField("callParams", &params)
+	logger.Debug("Handling RPC call")
+
+	kctx, err := h.clientPool.Context(params.Context)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	discoveryClient, err := kctx.DiscoveryClient()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	obj, err := client.
+		Resource(schema.GroupVersionResource{Group: params.Group, Version: params.Version, Resource: params.Resource}).
+		Namespace(params.Namespace).
+		Get(ctx, params.Name, metav1.GetOptions{})
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	sources := []eventSource{{gvr: coreEvents, field: "involvedObject.uid"}}
+	// events.k8s.io is missing from the oldest clusters
+	if _, err := discoveryClient.ServerResourcesForGroupVersion(eventsEvents.GroupVersion().String()); err == nil {
+		sources = append(sources, eventSource{gvr: eventsEvents, field: "regarding.uid"})
+	}
+
+	w := &eventWatch{events: make(map[types.UID]Event), changed: make(chan struct{}, 1)}
+	var synced []cache.InformerSynced
+	for _, source := range sources {
+		selector := fields.OneTermEqualSelector(source.field, string(obj.GetUID())).String()
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+			client,
+			30*time.Second,
+			obj.GetNamespace(),
+			func(options *metav1.ListOptions) {
+				options.FieldSelector = selector
+			},
+		)
+		informer := factory.ForResource(source.gvr).Informer()
+		if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    w.set,
+			UpdateFunc: func(_, newObj interface{}) { w.set(newObj) },
+			DeleteFunc: w.remove,
+		}); err != nil {
+			reply <- rpc.ErrorReply(call, err)
+			return err
+		}
+		synced = append(synced, informer.HasSynced)
+		factory.Start(ctx.Done())
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return nil
+	}
+
+	// changes are coalesced, the latest list is what matters
+	w.notify()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.changed:
+			if !rpc.Send(ctx, reply, call, EventList{UID: obj.GetUID(), Events: w.list()}) {
+				return nil
+			}
+		}
+	}
+}
+
+type eventWatch struct {
+	mux     sync.Mutex
+	events  map[types.UID]Event
+	changed chan struct{}
+}
+
+func (w *eventWatch) set(obj interface{}) {
+	un, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	w.mux.Lock()
+	w.events[un.GetUID()] = eventFrom(un)
+	w.mux.Unlock()
+	w.notify()
+}
+
+func (w *eventWatch) remove(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	un, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	w.mux.Lock()
+	delete(w.events, un.GetUID())
+	w.mux.Unlock()
+	w.notify()
+}
+
+func (w *eventWatch) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *eventWatch) list() []Event {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	events := make([]Event, 0, len(w.events))
+	for _, e := range w.events {
+		events = append(events, e)
+	}
+	return mergeRepeated(events)
+}
+
+// eventFrom reads an event of either API, the series, when there is one,
+// holds the count and the last occurrence.
+func eventFrom(un *unstructured.Unstructured) Event {
+	e := Event{Count: 1}
+	e.Type, _, _ = unstructured.NestedString(un.Object, "type")
+	e.Reason, _, _ = unstructured.NestedString(un.Object, "reason")
+	e.Message = firstString(un, []string{"message"}, []string{"note"})
+	e.Source = firstString(un, []string{"reportingController"}, []string{"reportingComponent"}, []string{"source", "component"})
+
+	if count, found, _ := unstructured.NestedInt64(un.Object, "series", "count"); found {
+		e.Count = count
+	} else if count := firstInt(un, []string{"count"}, []string{"deprecatedCount"}); count > 0 {
+		e.Count = count
+	}
+
+	created := firstTime(un, []string{"metadata", "creationTimestamp"})
+	eventTime := firstTime(un, []string{"eventTime"})
+	e.FirstTimestamp = firstNonZero(
+		firstTime(un, []string{"firstTimestamp"}, []string{"deprecatedFirstTimestamp"}),
+		eventTime,
+		created,
+	)
+	e.LastTimestamp = firstNonZero(
+		firstTime(un, []string{"series", "lastObservedTime"}),
+		firstTime(un, []string{"lastTimestamp"}, []string{"deprecatedLastTimestamp"}),
+		eventTime,
+		created,
+	)
+	return e
+}
+
+// mergeRepeated merges the events telling the same, which recorders failed
+// to merge in a series, and sorts them by last timestamp.
+func mergeRepeated(events []Event) []Event {
+	type key struct {
+		eventType, reason, message, source string
+	}
+	merged := make(map[key]*Event)
+	var keys []key
+	for i := range events {
+		e := events[i]
+		k := key{e.Type, e.Reason, e.Message, e.Source}
+		m, found := merged[k]
+		if !found {
+			merged[k] = &e
+			keys = append(keys, k)
+			continue
+		}
+		m.Count += e.Count
+		if e.FirstTimestamp.Before(m.FirstTimestamp) {
+			m.FirstTimestamp = e.FirstTimestamp
+		}
+		if e.LastTimestamp.After(m.LastTimestamp) {
+			m.LastTimestamp = e.LastTimestamp
+		}
+	}
+	result := make([]Event, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, *merged[k])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].LastTimestamp.Equal(result[j].LastTimestamp) {
+			return result[i].LastTimestamp.Before(result[j].LastTimestamp)
+		}
+		return result[i].Reason < result[j].Reason
+	})
+	return result
+}
+
+func firstString(un *unstructured.Unstructured, paths ...[]string) string {
+	for _, p := range paths {
+		if s, _, _ := unstructured.NestedString(un.Object, p...); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func firstInt(un *unstructured.Unstructured, paths ...[]string) int64 {
+	for _, p := range paths {
+		if n, _, _ := unstructured.NestedInt64(un.Object, p...); n != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func firstTime(un *unstructured.Unstructured, paths ...[]string) time.Time {
+	for _, p := range paths {
+		s, _, _ := unstructured.NestedString(un.Object, p...)
+		if s == "" {
+			continue
+		}
+		for _, layout := range []string{time.RFC3339Nano, time.RFC3339} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonZero(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+	"time"
+)
+
+func TestEventFrom(t *testing.T) {
+	core := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "v1",
+		"kind":           "Event",
+		"metadata":       map[string]interface{}{"name": "web.1", "creationTimestamp": "2023-05-01T10:00:00Z"},
+		"type":           "Warning",
+		"reason":         "BackOff",
+		"message":        "Back-off restarting failed container",
+		"source":         map[string]interface{}{"component": "kubelet"},
+		"count":          int64(7),
+		"firstTimestamp": "2023-05-01T10:00:00Z",
+		"lastTimestamp":  "2023-05-01T10:05:00Z",
+	}}
+	e := eventFrom(core)
+	assert.Equal(t, "Back-off restarting failed container", e.Message)
+	assert.Equal(t, "kubelet", e.Source)
+	assert.Equal(t, int64(7), e.Count)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), e.FirstTimestamp)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 5, 0, 0, time.UTC), e.LastTimestamp)
+
+	series := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":          "events.k8s.io/v1",
+		"kind":                "Event",
+		"metadata":            map[string]interface{}{"name": "web.2", "creationTimestamp": "2023-05-01T10:00:00Z"},
+		"type":                "Normal",
+		"reason":              "Pulled",
+		"note":                "Container image already present on machine",
+		"reportingController": "kubelet",
+		"eventTime":           "2023-05-01T10:00:00.000000Z",
+		"series":              map[string]interface{}{"count": int64(3), "lastObservedTime": "2023-05-01T10:09:30.123456Z"},
+	}}
+	e = eventFrom(series)
+	assert.Equal(t, "Container image already present on machine", e.Message)
+	assert.Equal(t, "kubelet", e.Source)
+	assert.Equal(t, int64(3), e.Count)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), e.FirstTimestamp)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 9, 30, 123456000, time.UTC), e.LastTimestamp)
+
+	single := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web.3", "creationTimestamp": "2023-05-01T11:00:00Z"},
+		"reason":   "Scheduled",
+	}}
+	e = eventFrom(single)
+	assert.Equal(t, int64(1), e.Count)
+	assert.Equal(t, time.Date(2023, 5, 1, 11, 0, 0, 0, time.UTC), e.LastTimestamp)
+}
+
+func TestMergeRepeated(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2023, 5, 1, 10, minute, 0, 0, time.UTC)
+	}
+	merged := mergeRepeated([]Event{
+		{Type: "Warning", Reason: "BackOff", Message: "Back-off", Source: "kubelet", Count: 4, FirstTimestamp: at(5), LastTimestamp: at(20)},
+		{Type: "Normal", Reason: "Scheduled", Message: "Assigned", Source: "default-scheduler", Count: 1, FirstTimestamp: at(0), LastTimestamp: at(0)},
+		{Type: "Warning", Reason: "BackOff", Message: "Back-off", Source: "kubelet", Count: 2, FirstTimestamp: at(1), LastTimestamp: at(3)},
+		{Type: "Normal", Reason: "Pulled", Message: "Pulled", Source: "kubelet", Count: 1, FirstTimestamp: at(1), LastTimestamp: at(1)},
+	})
+
+	assert.Equal(t, []Event{
+		{Type: "Normal", Reason: "Scheduled", Message: "Assigned", Source: "default-scheduler", Count: 1, FirstTimestamp: at(0), LastTimestamp: at(0)},
+		{Type: "Normal", Reason: "Pulled", Message: "Pulled", Source: "kubelet", Count: 1, FirstTimestamp: at(1), LastTimestamp: at(1)},
+		{Type: "Warning", Reason: "BackOff", Message: "Back-off", Source: "kubelet", Count: 6, FirstTimestamp: at(1), LastTimestamp: at(20)},
+	}, merged)
+}
@@ -0,0 +1,94 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api/stream"
+	"k8s-explore/api/stream/rpc"
+	"k8s-explore/drain"
+	"k8s-explore/kubeclient"
+	"k8s-explore/logging"
+	"time"
+)
+
+const Drain rpc.CallMethod = "nodes.drain"
+
+type paramsDrain struct {
+	Context            string `json:"context"`
+	Name               string `json:"name"`
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds"`
+	TimeoutSeconds     int64  `json:"timeoutSeconds"`
+}
+
+type DrainHandler struct {
+	clientPool *kubeclient.ClientPool
+	logger     *logrus.Entry
+}
+
+func NewDrainHandler(clientPool *kubeclient.ClientPool) *DrainHandler {
+	return &DrainHandler{
+		clientPool: clientPool,
+		logger:     logrus.WithField("handler", "stream/rpc/kube/nodes/drain"),
+	}
+}
+
+// Handle drains a node, each step being a drain.Event result. Cancelling the
+// call stops the evictions but leaves the node cordoned.
+func (h *DrainHandler) Handle(ctx context.Context, call rpc.Call, reply chan<- stream.Message) error {
+	if call.Method != Drain {
+		return errors.New("call has been miss dispatched")
+	}
+	logger := logging.WithRequestID(ctx, h.logger).
+		WithField("callId", call.ID).
+		WithField("callMethod", call.Method)
+
+	params := paramsDrain{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		logger.
+			WithError(err).
+			Warn("couldn't decode call params")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	logger = logger.WithField("callParams", &params)
+	logger.Debug("Handling RPC call")
+
+	kctx, err := h.clientPool.Context(params.Context)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	drainCtx := ctx
+	if params.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(ctx, time.Duration(params.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	err = drain.Drain(drainCtx, client, params.Name, drain.Options{
+		GracePeriodSeconds: params.GracePeriodSeconds,
+	}, func(event drain.Event) {
+		rpc.Send(ctx, reply, call, event)
+	})
+	if ctx.Err() != nil {
+		logger.Info("Drain cancelled, the node stays cordoned")
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out draining the node, it stays cordoned")
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Couldn't drain node")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	return nil
+}
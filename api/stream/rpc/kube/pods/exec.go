@@ -0,0 +1,250 @@
+package pods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"k8s-explore/api/stream"
+	"k8s-explore/api/stream/rpc"
+	"k8s-explore/kubeclient"
+	"k8s-explore/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+	"net/http"
+)
+
+const Exec rpc.CallMethod = "pods.exec"
+
+const (
+	ExecEventStarted = "started"
+	ExecEventStdout  = "stdout"
+	ExecEventStderr  = "stderr"
+	ExecEventExit    = "exit"
+	ExecEventError   = "error"
+)
+
+var defaultExecCommand = []string{"/bin/sh"}
+
+type paramsExec struct {
+	Context   string   `json:"context"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Container string   `json:"container"`
+	Command   []string `json:"command"`
+	TTY       bool     `json:"tty"`
+}
+
+// paramsExecInput is a follow-up message of an exec call, sent through the
+// .input method with the ID of the call. Stdin is base64 encoded like the
+// output, close ends stdin.
+type paramsExecInput struct {
+	Stdin  []byte                      `json:"stdin"`
+	Resize *remotecommand.TerminalSize `json:"resize"`
+	Close  bool                        `json:"close"`
+}
+
+// ExecEvent is a single result of an exec call. Data is base64 encoded in
+// JSON since terminal output isn't necessarily valid UTF-8.
+type ExecEvent struct {
+	Event    string `json:"event"`
+	Data     []byte `json:"data,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type ExecHandler struct {
+	clientPool *kubeclient.ClientPool
+	logger     *logrus.Entry
+}
+
+func NewExecHandler(clientPool *kubeclient.ClientPool) *ExecHandler {
+	return &ExecHandler{
+		clientPool: clientPool,
+		logger:     logrus.WithField("handler", "stream/rpc/kube/pods/exec"),
+	}
+}
+
+// AcceptsInput tells the dispatcher that exec calls take stdin and resizes.
+func (h *ExecHandler) AcceptsInput() bool {
+	return true
+}
+
+// Handle runs a command in a container over SPDY. The output is relayed as
+// replies, stdin and terminal resizes come as input of the call, and the
+// session ends with an exit event. Cancelling the call closes the session.
+func (h *ExecHandler) Handle(ctx context.Context, call rpc.Call, reply chan<- stream.Message) error {
+	if call.Method != Exec {
+		return errors.New("call has been miss dispatched")
+	}
+	logger := logging.WithRequestID(ctx, h.logger).
+		WithField("callId", call.ID).
+		WithField("callMethod", call.Method)
+
+	params := paramsExec{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		logger.
+			WithError(err).
+			Warn("couldn't decode call params")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	if len(params.Command) == 0 {
+		params.Command = defaultExecCommand
+	}
+
+	logger = logger.WithField("callParams", &params)
+	logger.Debug("Handling RPC call")
+
+	kctx, err := h.clientPool.Context(params.Context)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	req := client.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Namespace(params.Namespace).
+		Name(params.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: params.Container,
+			Command:   params.Command,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    !params.TTY,
+			TTY:       params.TTY,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(kctx.RESTConfig(), http.MethodPost, req.URL())
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	stdin, stdinWriter := io.Pipe()
+	sizes := &terminalSizeQueue{ctx: ctx, sizes: make(chan remotecommand.TerminalSize, 1)}
+	go func() {
+		defer stdinWriter.Close()
+		relayExecInput(ctx, logger, rpc.CallInput(ctx), stdinWriter, sizes)
+	}()
+
+	rpc.Send(ctx, reply, call, ExecEvent{Event: ExecEventStarted})
+	opts := remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: &execWriter{ctx: ctx, reply: reply, call: call, event: ExecEventStdout},
+		Tty:    params.TTY,
+	}
+	if params.TTY {
+		opts.TerminalSizeQueue = sizes
+	} else {
+		opts.Stderr = &execWriter{ctx: ctx, reply: reply, call: call, event: ExecEventStderr}
+	}
+	err = executor.StreamWithContext(ctx, opts)
+	// unblocks the input relay when the command exited on its own
+	stdin.Close()
+	if ctx.Err() != nil {
+		logger.Debug("Exec session cancelled")
+		return nil
+	}
+
+	exitCode := 0
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		exitCode = exitErr.ExitStatus()
+	} else if err != nil {
+		logger.WithError(err).Warn("Exec session failed")
+		rpc.Send(ctx, reply, call, ExecEvent{Event: ExecEventError, Message: err.Error()})
+		return err
+	}
+	rpc.Send(ctx, reply, call, ExecEvent{Event: ExecEventExit, ExitCode: &exitCode})
+	return nil
+}
+
+// relayExecInput feeds stdin and the terminal size queue from the input of
+// the call, until the call or the session is over.
+func relayExecInput(
+	ctx context.Context,
+	logger *logrus.Entry,
+	input <-chan json.RawMessage,
+	stdin *io.PipeWriter,
+	sizes *terminalSizeQueue,
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case raw := <-input:
+			msg := paramsExecInput{}
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				logger.WithError(err).Warn("couldn't decode exec input")
+				continue
+			}
+			if msg.Resize != nil {
+				sizes.push(*msg.Resize)
+			}
+			if len(msg.Stdin) > 0 {
+				if _, err := stdin.Write(msg.Stdin); err != nil {
+					// the session is over
+					return
+				}
+			}
+			if msg.Close {
+				return
+			}
+		}
+	}
+}
+
+type execWriter struct {
+	ctx   context.Context
+	reply chan<- stream.Message
+	call  rpc.Call
+	event string
+}
+
+func (w *execWriter) Write(p []byte) (int, error) {
+	// p is reused by the caller once Write returned
+	data := append([]byte{}, p...)
+	if !rpc.Send(w.ctx, w.reply, w.call, ExecEvent{Event: w.event, Data: data}) {
+		return 0, w.ctx.Err()
+	}
+	return len(p), nil
+}
+
+// terminalSizeQueue keeps the latest terminal size only, intermediate sizes
+// of a window being resized don't matter.
+type terminalSizeQueue struct {
+	ctx   context.Context
+	sizes chan remotecommand.TerminalSize
+}
+
+func (q *terminalSizeQueue) push(size remotecommand.TerminalSize) {
+	for {
+		select {
+		case q.sizes <- size:
+			return
+		default:
+		}
+		select {
+		case <-q.sizes:
+		default:
+		}
+	}
+}
+
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.ctx.Done():
+		return nil
+	}
+}
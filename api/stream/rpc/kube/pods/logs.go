@@ -0,0 +1,313 @@
+package pods
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"k8s-explore/api/stream"
+	"k8s-explore/api/stream/rpc"
+	"k8s-explore/kubeclient"
+	"k8s-explore/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Logs rpc.CallMethod = "pods.logs"
+
+const (
+	LogEventOpened = "opened"
+	LogEventLine   = "line"
+	LogEventClosed = "closed"
+	LogEventError  = "error"
+	LogEventEnd    = "end"
+)
+
+type paramsLogs struct {
+	Context       string `json:"context"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	LabelSelector string `json:"labelSelector"`
+	Container     string `json:"container"`
+	Follow        bool   `json:"follow"`
+	TailLines     *int64 `json:"tailLines"`
+	SinceSeconds  *int64 `json:"sinceSeconds"`
+	Timestamps    bool   `json:"timestamps"`
+	Previous      bool   `json:"previous"`
+}
+
+// LogEvent is a single result of a logs call, lines of the different
+// containers are interleaved as they come and tagged with their origin.
+type LogEvent struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	Line      string `json:"line,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type LogsHandler struct {
+	clientPool *kubeclient.ClientPool
+	logger     *logrus.Entry
+}
+
+func NewLogsHandler(clientPool *kubeclient.ClientPool) *LogsHandler {
+	return &LogsHandler{
+		clientPool: clientPool,
+		logger:     logrus.WithField("handler", "stream/rpc/kube/pods/logs"),
+	}
+}
+
+// Handle streams the logs of a pod, or of every pod matching the label
+// selector. When following, pods showing up later and restarted containers
+// are streamed as well until the call is cancelled.
+func (h *LogsHandler) Handle(ctx context.Context, call rpc.Call, reply chan<- stream.Message) error {
+	if call.Method != Logs {
+		return errors.New("call has been miss dispatched")
+	}
+	logger := logging.WithRequestID(ctx, h.logger).
+		WithField("callId", call.ID).
+		WithField("callMethod", call.Method)
+
+	params := paramsLogs{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		logger.
+			WithError(err).
+			Warn("couldn't decode call params")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	if params.Name == "" && params.LabelSelector == "" {
+		err := errors.New("either a pod name or a label selector is required")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	logger = logger.WithField("callParams", &params)
+	logger.Debug("Handling RPC call")
+
+	kctx, err := h.clientPool.Context(params.Context)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	s := &logStreamer{
+		ctx:     ctx,
+		call:    call,
+		reply:   reply,
+		client:  client,
+		params:  params,
+		logger:  logger,
+		streams: make(map[string]*containerStream),
+	}
+	if params.Follow {
+		return s.follow()
+	}
+
+	var pods []corev1.Pod
+	if params.Name != "" {
+		pod, err := client.CoreV1().Pods(params.Namespace).Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			reply <- rpc.ErrorReply(call, err)
+			return err
+		}
+		pods = append(pods, *pod)
+	} else {
+		list, err := client.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.LabelSelector})
+		if err != nil {
+			reply <- rpc.ErrorReply(call, err)
+			return err
+		}
+		pods = list.Items
+	}
+	for i := range pods {
+		s.startPod(&pods[i])
+	}
+	s.wg.Wait()
+	s.send(LogEvent{Event: LogEventEnd})
+	return nil
+}
+
+type containerStream struct {
+	active       bool
+	restartCount int32
+	// closedAt is when the last stream of the container ended, a restarted
+	// container is streamed from there on
+	closedAt time.Time
+}
+
+type logStreamer struct {
+	ctx    context.Context
+	call   rpc.Call
+	reply  chan<- stream.Message
+	client kubernetes.Interface
+	params paramsLogs
+	logger *logrus.Entry
+
+	mux     sync.Mutex
+	wg      sync.WaitGroup
+	streams map[string]*containerStream
+}
+
+func (s *logStreamer) follow() error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		s.client,
+		30*time.Second,
+		informers.WithNamespace(s.params.Namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = s.params.LabelSelector
+			if s.params.Name != "" {
+				options.FieldSelector = "metadata.name=" + s.params.Name
+			}
+		}),
+	)
+	informer := factory.Core().V1().Pods().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				s.startPod(pod)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if pod, ok := newObj.(*corev1.Pod); ok {
+				s.startPod(pod)
+			}
+		},
+	}); err != nil {
+		s.send(LogEvent{Event: LogEventError, Message: err.Error()})
+		return err
+	}
+	factory.Start(s.ctx.Done())
+	<-s.ctx.Done()
+	factory.Shutdown()
+	// streams are started under the lock, once it's released no new one
+	// can be added to the wait group
+	s.mux.Lock()
+	s.mux.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// startPod opens a stream for every started container of the pod which
+// isn't streamed yet.
+func (s *logStreamer) startPod(pod *corev1.Pod) {
+	statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if s.params.Container != "" && status.Name != s.params.Container {
+			continue
+		}
+		// logs are only available once the container started
+		if status.State.Running == nil && status.State.Terminated == nil && !s.params.Previous {
+			continue
+		}
+		s.startContainer(pod.Namespace, pod.Name, status)
+	}
+}
+
+func (s *logStreamer) startContainer(namespace string, pod string, status corev1.ContainerStatus) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.ctx.Err() != nil {
+		return
+	}
+	key := namespace + "/" + pod + "/" + status.Name
+	cs, found := s.streams[key]
+	if found && (cs.active || !s.params.Follow || cs.restartCount == status.RestartCount) {
+		return
+	}
+	opts := &corev1.PodLogOptions{
+		Container:    status.Name,
+		Follow:       s.params.Follow,
+		Timestamps:   s.params.Timestamps,
+		Previous:     s.params.Previous,
+		TailLines:    s.params.TailLines,
+		SinceSeconds: s.params.SinceSeconds,
+	}
+	if found {
+		// the container restarted, don't replay what was already sent
+		opts.TailLines = nil
+		opts.SinceSeconds = nil
+		opts.SinceTime = &metav1.Time{Time: cs.closedAt}
+	} else {
+		cs = &containerStream{}
+		s.streams[key] = cs
+	}
+	cs.active = true
+	cs.restartCount = status.RestartCount
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.streamContainer(LogEvent{Namespace: namespace, Pod: pod, Container: status.Name}, opts)
+		s.mux.Lock()
+		cs.active = false
+		cs.closedAt = time.Now()
+		s.mux.Unlock()
+	}()
+}
+
+// streamContainer sends the log lines of a container, origin tags every
+// event with the namespace, pod and container it comes from.
+func (s *logStreamer) streamContainer(origin LogEvent, opts *corev1.PodLogOptions) {
+	logger := s.logger.WithField("pod", origin.Pod).WithField("container", origin.Container)
+	event := func(eventType string) LogEvent {
+		e := origin
+		e.Event = eventType
+		return e
+	}
+
+	body, err := s.client.CoreV1().Pods(origin.Namespace).GetLogs(origin.Pod, opts).Stream(s.ctx)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			logger.WithError(err).Warn("Couldn't open container log stream")
+			e := event(LogEventError)
+			e.Message = err.Error()
+			s.send(e)
+		}
+		return
+	}
+	defer body.Close()
+	s.send(event(LogEventOpened))
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			e := event(LogEventLine)
+			e.Line = strings.TrimSuffix(line, "\n")
+			if !s.send(e) {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF && s.ctx.Err() == nil {
+				logger.WithError(err).Warn("Couldn't read container log stream")
+				e := event(LogEventError)
+				e.Message = err.Error()
+				s.send(e)
+			}
+			break
+		}
+	}
+	s.send(event(LogEventClosed))
+}
+
+// send replies unless the call is over, in which case false is returned.
+func (s *logStreamer) send(event LogEvent) bool {
+	return rpc.Send(s.ctx, s.reply, s.call, event)
+}
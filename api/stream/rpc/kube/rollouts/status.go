@@ -0,0 +1,121 @@
+package rollouts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"k8s-explore/api/stream"
+	"k8s-explore/api/stream/rpc"
+	"k8s-explore/kubeclient"
+	"k8s-explore/logging"
+	"k8s-explore/rollout"
+	"time"
+)
+
+const Status rpc.CallMethod = "rollouts.status"
+
+const (
+	StatusEventProgress = "progress"
+	StatusEventComplete = "complete"
+	StatusEventFailed   = "failed"
+)
+
+type paramsStatus struct {
+	Context        string `json:"context"`
+	Resource       string `json:"resource"`
+	Namespace      string `json:"namespace"`
+	Name           string `json:"name"`
+	TimeoutSeconds int64  `json:"timeoutSeconds"`
+}
+
+// StatusEvent is a step of a rollout, the call ends after the complete or
+// failed event.
+type StatusEvent struct {
+	Event string `json:"event"`
+	rollout.Status
+}
+
+type StatusHandler struct {
+	clientPool *kubeclient.ClientPool
+	logger     *logrus.Entry
+}
+
+func NewStatusHandler(clientPool *kubeclient.ClientPool) *StatusHandler {
+	return &StatusHandler{
+		clientPool: clientPool,
+		logger:     logrus.WithField("handler", "stream/rpc/kube/rollouts/status"),
+	}
+}
+
+// Handle follows the rollout of a workload until it completes, or fails when
+// the progress deadline of a deployment or the timeout of the call passes.
+func (h *StatusHandler) Handle(ctx context.Context, call rpc.Call, reply chan<- stream.Message) error {
+	if call.Method != Status {
+		return errors.New("call has been miss dispatched")
+	}
+	logger := logging.WithRequestID(ctx, h.logger).
+		WithField("callId", call.ID).
+		WithField("callMethod", call.Method)
+
+	params := paramsStatus{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		logger.
+			WithError(err).
+			Warn("couldn't decode call params")
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	logger = logger.WithField("callParams", &params)
+	logger.Debug("Handling RPC call")
+
+	kctx, err := h.clientPool.Context(params.Context)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	client, err := kctx.DynamicClient()
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	workload, err := rollout.NewWorkload(client, params.Resource, params.Namespace, params.Name)
+	if err != nil {
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+
+	watchCtx := ctx
+	if params.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		watchCtx, cancel = context.WithTimeout(ctx, time.Duration(params.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	err = workload.WatchStatus(watchCtx, func(status rollout.Status) {
+		event := StatusEvent{Event: StatusEventProgress, Status: status}
+		switch {
+		case status.Done:
+			event.Event = StatusEventComplete
+		case status.Failed:
+			event.Event = StatusEventFailed
+		}
+		rpc.Send(ctx, reply, call, event)
+	})
+	switch {
+	case ctx.Err() != nil:
+		// cancelled by the client
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		rpc.Send(ctx, reply, call, StatusEvent{Event: StatusEventFailed, Status: rollout.Status{
+			Failed:  true,
+			Message: fmt.Sprintf("timed out waiting for the rollout after %ds", params.TimeoutSeconds),
+		}})
+		return nil
+	case err != nil:
+		reply <- rpc.ErrorReply(call, err)
+		return err
+	}
+	return nil
+}
@@ -25,15 +25,48 @@ type Call struct {
 
 const (
 	callMethodCancel CallMethod = ".cancel"
+	// callMethodInput carries the params of a follow-up message to the active
+	// call with the same ID, e.g. the stdin of an exec session
+	callMethodInput CallMethod = ".input"
+
+	// callInputBuffer is the input a call may have pending, more is refused
+	callInputBuffer = 64
 )
 
+type callInputKey struct{}
+
+// CallInput returns the follow-up messages sent by the client to the call
+// handled under ctx, in the order they were sent.
+func CallInput(ctx context.Context) <-chan json.RawMessage {
+	input, _ := ctx.Value(callInputKey{}).(chan json.RawMessage)
+	return input
+}
+
+type activeCall struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	input  chan json.RawMessage
+	// acceptsInput is set when the handler of the call reads its input
+	acceptsInput bool
+	// claimed is set once a handler runs the call, a call registered by
+	// HandleInline isn't yet
+	claimed bool
+}
+
 type CallHandler interface {
 	Handle(ctx context.Context, call Call, reply chan<- stream.Message) error
 }
 
+// InputHandler is a CallHandler which reads the input of its calls through
+// CallInput, the input sent to the calls of other handlers is refused.
+type InputHandler interface {
+	CallHandler
+	AcceptsInput() bool
+}
+
 type CallDispatcher struct {
 	handlers    map[CallMethod]CallHandler
-	activeCalls map[CallID]context.CancelFunc
+	activeCalls map[CallID]*activeCall
 	activeLock  sync.Mutex
 	logger      *logrus.Entry
 }
@@ -41,7 +74,7 @@ type CallDispatcher struct {
 func NewCallDispatcher() *CallDispatcher {
 	return &CallDispatcher{
 		handlers:    make(map[CallMethod]CallHandler),
-		activeCalls: make(map[CallID]context.CancelFunc),
+		activeCalls: make(map[CallID]*activeCall),
 		activeLock:  sync.Mutex{},
 		logger:      logrus.WithField("module", "stream/rpc/callDispatcher"),
 	}
@@ -65,9 +98,9 @@ func (d *CallDispatcher) Handle(ctx context.Context, msg stream.Message, reply c
 	if call.Method == callMethodCancel {
 		d.activeLock.Lock()
 		defer d.activeLock.Unlock()
-		if cancel, found := d.activeCalls[call.ID]; found {
-			cancel()
-			delete(d.activeCalls, call.ID)
+		// the call handler removes the call once it returned
+		if active, found := d.activeCalls[call.ID]; found {
+			active.cancel()
 		} else {
 			logger.Warn("active rpc call not found - nothing to cancel.")
 		}
@@ -82,19 +115,120 @@ func (d *CallDispatcher) Handle(ctx context.Context, msg stream.Message, reply c
 		return nil
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	active := d.claim(ctx, call.ID, handler)
+	if active == nil {
+		logger.Warn("RPC call id already in use")
+		reply <- koReply(call, "Duplicate call id")
+		return nil
+	}
+	defer active.cancel()
+	err := handler.Handle(active.ctx, call, reply)
 
 	d.activeLock.Lock()
-	d.activeCalls[call.ID] = cancel
+	if d.activeCalls[call.ID] == active {
+		delete(d.activeCalls, call.ID)
+	}
 	d.activeLock.Unlock()
 
-	err := handler.Handle(ctx, call, reply)
+	return err
+}
 
+// HandleInline takes the input of the active calls right in the read loop of
+// the connection, keeping their order. Everything else is dispatched as usual.
+func (d *CallDispatcher) HandleInline(ctx context.Context, msg stream.Message, write func(stream.Message)) bool {
+	call := Call{}
+	if err := json.Unmarshal(msg, &call); err != nil || call.Method == callMethodCancel {
+		return false
+	}
+	if call.Method != callMethodInput {
+		// registering the call right away lets the input and the cancel
+		// sent next find it
+		if handler, found := d.handlers[call.Method]; found {
+			d.register(ctx, call.ID, handler)
+		}
+		return false
+	}
 	d.activeLock.Lock()
-	delete(d.activeCalls, call.ID)
+	active, found := d.activeCalls[call.ID]
 	d.activeLock.Unlock()
+	if !found {
+		logging.WithRequestID(ctx, d.logger).
+			WithField("callId", call.ID).
+			Warn("active rpc call not found - input dropped.")
+		write(koReply(call, "Unknown call"))
+		return true
+	}
+	if !active.acceptsInput {
+		write(koReply(call, "Call doesn't take input"))
+		return true
+	}
+	// blocking would stall the whole connection, the cancel of the call
+	// included, until the handler catches up
+	select {
+	case active.input <- call.Params:
+	default:
+		logging.WithRequestID(ctx, d.logger).
+			WithField("callId", call.ID).
+			Warn("rpc call input buffer full - input dropped.")
+		write(koReply(call, "Input buffer full"))
+	}
+	return true
+}
 
-	return err
+// register makes a call active, unless it already is.
+func (d *CallDispatcher) register(ctx context.Context, id CallID, handler CallHandler) *activeCall {
+	d.activeLock.Lock()
+	defer d.activeLock.Unlock()
+	return d.registerLocked(ctx, id, handler)
+}
+
+// claim returns the active call for a handler to run, nil when another
+// handler runs a call with the same id already.
+func (d *CallDispatcher) claim(ctx context.Context, id CallID, handler CallHandler) *activeCall {
+	d.activeLock.Lock()
+	defer d.activeLock.Unlock()
+	active := d.registerLocked(ctx, id, handler)
+	if active.claimed {
+		return nil
+	}
+	active.claimed = true
+	return active
+}
+
+func (d *CallDispatcher) registerLocked(ctx context.Context, id CallID, handler CallHandler) *activeCall {
+	if active, found := d.activeCalls[id]; found {
+		return active
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	input := make(chan json.RawMessage, callInputBuffer)
+	inputHandler, ok := handler.(InputHandler)
+	active := &activeCall{
+		ctx:          context.WithValue(ctx, callInputKey{}, input),
+		cancel:       cancel,
+		input:        input,
+		acceptsInput: ok && inputHandler.AcceptsInput(),
+	}
+	d.activeCalls[id] = active
+	return active
+}
+
+// Send replies with a result of the call, unless the call ended first.
+func Send(ctx context.Context, reply chan<- stream.Message, call Call, result interface{}) bool {
+	msg, err := json.Marshal(map[string]interface{}{"id": call.ID, "result": result})
+	if err != nil {
+		panic(err)
+	}
+	select {
+	case reply <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ErrorReply is the reply of a call which failed.
+func ErrorReply(call Call, err error) stream.Message {
+	return koReply(call, err.Error())
 }
 
 func okReply(call Call) stream.Message {
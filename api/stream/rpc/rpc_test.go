@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/api/stream"
+	"testing"
+	"time"
+)
+
+// echoHandler replies with every input of the call until it's cancelled.
+type echoHandler struct {
+	started chan struct{}
+}
+
+func (h *echoHandler) Handle(ctx context.Context, call Call, reply chan<- stream.Message) error {
+	close(h.started)
+	input := CallInput(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case params := <-input:
+			reply <- stream.Message(params)
+		}
+	}
+}
+
+func (h *echoHandler) AcceptsInput() bool {
+	return true
+}
+
+// waitHandler waits for its call to be cancelled, reading no input.
+type waitHandler struct {
+	started      chan struct{}
+	acceptsInput bool
+}
+
+func (h *waitHandler) Handle(ctx context.Context, call Call, reply chan<- stream.Message) error {
+	close(h.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (h *waitHandler) AcceptsInput() bool {
+	return h.acceptsInput
+}
+
+func message(id string, method CallMethod, params string) stream.Message {
+	msg, err := json.Marshal(Call{ID: CallID(id), Method: method, Params: json.RawMessage(params)})
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+// dispatch mimics the read loop of a connection: inline first, then a
+// goroutine per call.
+func dispatch(d *CallDispatcher, msg stream.Message, reply chan stream.Message) chan error {
+	done := make(chan error, 1)
+	if d.HandleInline(context.Background(), msg, func(m stream.Message) { reply <- m }) {
+		close(done)
+		return done
+	}
+	go func() {
+		done <- d.Handle(context.Background(), msg, reply)
+	}()
+	return done
+}
+
+func receive(t *testing.T, reply chan stream.Message) string {
+	select {
+	case msg := <-reply:
+		return string(msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply")
+		return ""
+	}
+}
+
+func TestCallDispatcher_Input(t *testing.T) {
+	d := NewCallDispatcher()
+	d.RegisterCallHandler("echo", &echoHandler{started: make(chan struct{})})
+	inputs := callInputBuffer
+	reply := make(chan stream.Message, inputs)
+
+	done := dispatch(d, message("1", "echo", `{}`), reply)
+	// likely sent before the handler runs
+	for i := 0; i < inputs; i++ {
+		dispatch(d, message("1", callMethodInput, fmt.Sprint(i)), reply)
+	}
+	for i := 0; i < inputs; i++ {
+		assert.Equal(t, fmt.Sprint(i), receive(t, reply))
+	}
+
+	dispatch(d, message("1", callMethodCancel, `{}`), reply)
+	assert.JSONEq(t, `{"id":"1","result":"ok"}`, receive(t, reply))
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, d.activeCalls)
+}
+
+func TestCallDispatcher_InputRefused(t *testing.T) {
+	d := NewCallDispatcher()
+	stalled := &waitHandler{started: make(chan struct{}), acceptsInput: true}
+	watch := &waitHandler{started: make(chan struct{})}
+	d.RegisterCallHandler("stalled", stalled)
+	d.RegisterCallHandler("watch", watch)
+	reply := make(chan stream.Message, 1)
+
+	first := dispatch(d, message("1", "stalled", `{}`), reply)
+	second := dispatch(d, message("2", "watch", `{}`), reply)
+	<-stalled.started
+	<-watch.started
+
+	// the read loop goes on once the buffer of a call is full
+	for i := 0; i < callInputBuffer; i++ {
+		dispatch(d, message("1", callMethodInput, fmt.Sprint(i)), reply)
+	}
+	dispatch(d, message("1", callMethodInput, `"more"`), reply)
+	assert.JSONEq(t, `{"id":"1","error":"Input buffer full"}`, receive(t, reply))
+
+	dispatch(d, message("2", callMethodInput, `"ls\n"`), reply)
+	assert.JSONEq(t, `{"id":"2","error":"Call doesn't take input"}`, receive(t, reply))
+
+	for id, done := range map[string]chan error{"1": first, "2": second} {
+		dispatch(d, message(id, callMethodCancel, `{}`), reply)
+		assert.JSONEq(t, fmt.Sprintf(`{"id":%q,"result":"ok"}`, id), receive(t, reply))
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}
+}
+
+func TestCallDispatcher_UnknownCall(t *testing.T) {
+	d := NewCallDispatcher()
+	reply := make(chan stream.Message, 1)
+
+	<-dispatch(d, message("7", callMethodInput, `"ls\n"`), reply)
+	assert.JSONEq(t, `{"id":"7","error":"Unknown call"}`, receive(t, reply))
+
+	<-dispatch(d, message("7", "unknown", `{}`), reply)
+	assert.JSONEq(t, `{"id":"7","error":"Unknown method"}`, receive(t, reply))
+	assert.Empty(t, d.activeCalls)
+}
+
+func TestCallDispatcher_DuplicateID(t *testing.T) {
+	d := NewCallDispatcher()
+	handler := &echoHandler{started: make(chan struct{})}
+	d.RegisterCallHandler("echo", handler)
+	reply := make(chan stream.Message, 1)
+
+	first := dispatch(d, message("1", "echo", `{}`), reply)
+	<-handler.started
+	assert.NoError(t, <-dispatch(d, message("1", "echo", `{}`), reply))
+	assert.JSONEq(t, `{"id":"1","error":"Duplicate call id"}`, receive(t, reply))
+
+	// the first call is still running
+	dispatch(d, message("1", callMethodInput, `"still here"`), reply)
+	assert.Equal(t, `"still here"`, receive(t, reply))
+
+	dispatch(d, message("1", callMethodCancel, `{}`), reply)
+	assert.JSONEq(t, `{"id":"1","result":"ok"}`, receive(t, reply))
+	assert.ErrorIs(t, <-first, context.Canceled)
+	assert.Empty(t, d.activeCalls)
+}
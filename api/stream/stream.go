@@ -18,6 +18,14 @@ type MessageHandler interface {
 	Handle(ctx context.Context, msg Message, reply chan<- Message) error
 }
 
+// InlineMessageHandler is implemented by the handlers taking some messages
+// right in the read loop of the connection, for the ones which must keep
+// their order. HandleInline returns false for the messages to dispatch as
+// usual and must not block for long.
+type InlineMessageHandler interface {
+	HandleInline(ctx context.Context, msg Message, write func(Message)) bool
+}
+
 type Handler struct {
 	api.Handler
 
@@ -83,10 +91,13 @@ func (d *MessageDispatcher) dispatchLoop() {
 		partial := struct {
 			Type MessageType `json:"type"`
 		}{}
-		if err := json.Unmarshal(msg, partial); err != nil {
+		if err := json.Unmarshal(msg, &partial); err != nil {
 			d.logger.WithError(err).WithField("Message", msg).Warn("can not decode websocket message")
 			continue
 		}
+		if handler, ok := d.handlers[partial.Type].(InlineMessageHandler); ok && handler.HandleInline(d.ctx, msg, d.writeMessage) {
+			continue
+		}
 		go d.dispatchMessage(msg, partial.Type)
 	}
 	d.cancel()
@@ -0,0 +1,183 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sync"
+	"time"
+)
+
+const (
+	EventCordoned = "cordoned"
+	EventSkipped  = "skipped"
+	EventEvicting = "evicting"
+	EventRetrying = "retrying"
+	EventEvicted  = "evicted"
+	EventFailed   = "failed"
+	EventDrained  = "drained"
+
+	// evictionRetryInterval is waited after a refused eviction, unless the
+	// server tells how long to wait
+	evictionRetryInterval = 5 * time.Second
+	deletionPollInterval  = time.Second
+)
+
+// Event is a step of a drain, tied to a pod unless it's about the node.
+type Event struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type Options struct {
+	// GracePeriodSeconds overrides the termination grace period of the pods
+	GracePeriodSeconds *int64
+}
+
+// Cordon marks a node unschedulable.
+func Cordon(ctx context.Context, client kubernetes.Interface, name string) (*corev1.Node, error) {
+	return setUnschedulable(ctx, client, name, true)
+}
+
+func Uncordon(ctx context.Context, client kubernetes.Interface, name string) (*corev1.Node, error) {
+	return setUnschedulable(ctx, client, name, false)
+}
+
+func setUnschedulable(ctx context.Context, client kubernetes.Interface, name string, unschedulable bool) (*corev1.Node, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"unschedulable": unschedulable},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+// Drain cordons a node then evicts its pods, all at once, and waits for them
+// to be gone. DaemonSet pods, which would come back, and mirror pods, which
+// the kubelet owns, are left alone. Evictions refused by a disruption
+// budget are retried until ctx ends, the node stays cordoned either way.
+func Drain(ctx context.Context, client kubernetes.Interface, name string, opts Options, report func(Event)) error {
+	// pods are evicted concurrently, their events are reported one by one
+	var reportLock sync.Mutex
+	emit := func(e Event) {
+		reportLock.Lock()
+		defer reportLock.Unlock()
+		report(e)
+	}
+
+	if _, err := Cordon(ctx, client, name); err != nil {
+		return err
+	}
+	emit(Event{Event: EventCordoned, Message: fmt.Sprintf("node %s cordoned", name)})
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var failedLock sync.Mutex
+	failed := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if reason := skipReason(pod); reason != "" {
+			emit(Event{Event: EventSkipped, Namespace: pod.Namespace, Pod: pod.Name, Message: reason})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := evict(ctx, client, pod, opts, emit); err != nil {
+				if ctx.Err() == nil {
+					emit(Event{Event: EventFailed, Namespace: pod.Namespace, Pod: pod.Name, Message: err.Error()})
+				}
+				failedLock.Lock()
+				failed++
+				failedLock.Unlock()
+				return
+			}
+			emit(Event{Event: EventEvicted, Namespace: pod.Namespace, Pod: pod.Name})
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("couldn't evict %d pods of node %s", failed, name)
+	}
+	emit(Event{Event: EventDrained, Message: fmt.Sprintf("node %s drained", name)})
+	return nil
+}
+
+// skipReason tells why a pod is left on the node, if it is.
+func skipReason(pod *corev1.Pod) string {
+	if _, found := pod.Annotations[corev1.MirrorPodAnnotationKey]; found {
+		return "mirror pod"
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return "managed by DaemonSet " + owner.Name
+	}
+	return ""
+}
+
+func evict(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, opts Options, report func(Event)) error {
+	report(Event{Event: EventEvicting, Namespace: pod.Namespace, Pod: pod.Name})
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+		DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: opts.GracePeriodSeconds},
+	}
+	for {
+		err := client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		if err == nil {
+			break
+		}
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if !apierrors.IsTooManyRequests(err) {
+			return err
+		}
+		// a disruption budget doesn't allow it yet
+		delay := evictionRetryInterval
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+			delay = time.Duration(seconds) * time.Second
+		}
+		report(Event{Event: EventRetrying, Namespace: pod.Namespace, Pod: pod.Name, Message: err.Error()})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return waitForDeletion(ctx, client, pod)
+}
+
+// waitForDeletion waits for the pod to be gone, a pod of the same name but
+// another UID is a replacement.
+func waitForDeletion(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) error {
+	return wait.PollUntilContextCancel(ctx, deletionPollInterval, true, func(ctx context.Context) (bool, error) {
+		current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return current.UID != pod.UID, nil
+	})
+}
@@ -0,0 +1,77 @@
+package drain
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func pod(name string, annotations map[string]string, owner *metav1.OwnerReference) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, UID: types.UID("uid-" + name), Annotations: annotations},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+	}
+	if owner != nil {
+		p.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return p
+}
+
+func TestSkipReason(t *testing.T) {
+	controller := true
+	assert.Equal(t, "mirror pod", skipReason(pod("etcd", map[string]string{corev1.MirrorPodAnnotationKey: "abc"}, nil)))
+	assert.Equal(t, "managed by DaemonSet fluentd", skipReason(pod("fluentd-x", nil,
+		&metav1.OwnerReference{Kind: "DaemonSet", Name: "fluentd", Controller: &controller})))
+	assert.Equal(t, "", skipReason(pod("web-x", nil,
+		&metav1.OwnerReference{Kind: "ReplicaSet", Name: "web", Controller: &controller})))
+}
+
+func TestDrain(t *testing.T) {
+	controller := true
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+	client := fake.NewSimpleClientset(
+		node,
+		pod("web-x", nil, &metav1.OwnerReference{Kind: "ReplicaSet", Name: "web", Controller: &controller}),
+		pod("fluentd-x", nil, &metav1.OwnerReference{Kind: "DaemonSet", Name: "fluentd", Controller: &controller}),
+	)
+	refused := false
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		// the disruption budget refuses the first eviction
+		if !refused {
+			refused = true
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 1)
+		}
+		return true, nil, client.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), "shop", name)
+	})
+
+	var events []string
+	err := Drain(context.Background(), client, "node-a", Options{}, func(e Event) {
+		events = append(events, e.Event+" "+e.Pod)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"cordoned ",
+		"skipped fluentd-x",
+		"evicting web-x",
+		"retrying web-x",
+		"evicted web-x",
+		"drained ",
+	}, events)
+
+	drained, err := client.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, drained.Spec.Unschedulable)
+	_, err = client.CoreV1().Pods("shop").Get(context.Background(), "fluentd-x", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,312 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// secretType is the type of the secrets of the default storage driver
+	// of Helm 3, one secret per revision of a release
+	secretType   = "helm.sh/release.v1"
+	secretPrefix = "sh.helm.release.v1."
+)
+
+var ErrReleaseNotFound = errors.New("release not found")
+
+var (
+	secretsResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	gzipMagic       = []byte{0x1f, 0x8b, 0x08}
+)
+
+// Release is a revision of a Helm release.
+type Release struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Chart        string    `json:"chart"`
+	ChartName    string    `json:"chartName"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description,omitempty"`
+	Updated      time.Time `json:"updated"`
+}
+
+// ReleaseDetail is a revision with what it installed. Values are the values
+// given to the release, merged into the defaults of the chart when all
+// values were asked for.
+type ReleaseDetail struct {
+	Release
+	Notes    string                 `json:"notes,omitempty"`
+	Manifest string                 `json:"manifest"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+// release decodes the part of the release record of Helm read here.
+type release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		LastDeployed helmTime `json:"last_deployed"`
+		Description  string   `json:"description"`
+		Status       string   `json:"status"`
+		Notes        string   `json:"notes"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+		Values map[string]interface{} `json:"values"`
+	} `json:"chart"`
+	Config   map[string]interface{} `json:"config"`
+	Manifest string                 `json:"manifest"`
+}
+
+// helmTime is a time of a release record, Helm writes zero times as "".
+type helmTime struct {
+	time.Time
+}
+
+func (t *helmTime) UnmarshalJSON(b []byte) error {
+	if string(b) == `""` || string(b) == "null" {
+		return nil
+	}
+	return json.Unmarshal(b, &t.Time)
+}
+
+func (r *release) summary() Release {
+	return Release{
+		Name:         r.Name,
+		Namespace:    r.Namespace,
+		Revision:     r.Version,
+		Chart:        r.Chart.Metadata.Name + "-" + r.Chart.Metadata.Version,
+		ChartName:    r.Chart.Metadata.Name,
+		ChartVersion: r.Chart.Metadata.Version,
+		AppVersion:   r.Chart.Metadata.AppVersion,
+		Status:       r.Info.Status,
+		Description:  r.Info.Description,
+		Updated:      r.Info.LastDeployed.Time,
+	}
+}
+
+func (r *release) detail(allValues bool) *ReleaseDetail {
+	values := r.Config
+	if allValues {
+		values = mergeValues(r.Chart.Values, r.Config)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return &ReleaseDetail{
+		Release:  r.summary(),
+		Notes:    r.Info.Notes,
+		Manifest: r.Manifest,
+		Values:   values,
+	}
+}
+
+// List returns the latest revision of every release of a namespace, of
+// every namespace without one. The labels Helm puts on its secrets tell the
+// latest revisions, only those are decoded. Secrets which can't be decoded
+// are skipped, as Helm does.
+func List(ctx context.Context, client dynamic.Interface, namespace string, logger *logrus.Entry) ([]Release, error) {
+	secrets, err := listSecrets(ctx, client, namespace, labels.Set{"owner": "helm"})
+	if err != nil {
+		return nil, err
+	}
+	latest := latestRevisions(secrets, logger)
+	releases := make([]Release, 0, len(latest))
+	for _, secret := range latest {
+		r, err := decodeSecret(secret)
+		if err != nil {
+			logger.WithError(err).Warn("Skipping Helm release secret")
+			continue
+		}
+		releases = append(releases, r.summary())
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+	return releases, nil
+}
+
+// History returns the revisions Helm keeps of a release, oldest first.
+func History(ctx context.Context, client dynamic.Interface, namespace string, name string, logger *logrus.Entry) ([]Release, error) {
+	secrets, err := listSecrets(ctx, client, namespace, labels.Set{"owner": "helm", "name": name})
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrReleaseNotFound, name)
+	}
+	history := make([]Release, 0, len(secrets))
+	for i := range secrets {
+		r, err := decodeSecret(&secrets[i])
+		if err != nil {
+			logger.WithError(err).Warn("Skipping Helm release secret")
+			continue
+		}
+		history = append(history, r.summary())
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Revision < history[j].Revision
+	})
+	return history, nil
+}
+
+// Get returns a revision of a release, 0 being the latest one.
+func Get(ctx context.Context, client dynamic.Interface, namespace string, name string, revision int, allValues bool, logger *logrus.Entry) (*ReleaseDetail, error) {
+	var secret *unstructured.Unstructured
+	if revision == 0 {
+		secrets, err := listSecrets(ctx, client, namespace, labels.Set{"owner": "helm", "name": name})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range latestRevisions(secrets, logger) {
+			secret = s
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("%w: %s", ErrReleaseNotFound, name)
+		}
+	} else {
+		var err error
+		secret, err = client.Resource(secretsResource).Namespace(namespace).
+			Get(ctx, fmt.Sprintf("%s%s.v%d", secretPrefix, name, revision), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s revision %d", ErrReleaseNotFound, name, revision)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	r, err := decodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return r.detail(allValues), nil
+}
+
+func listSecrets(ctx context.Context, client dynamic.Interface, namespace string, selector labels.Set) ([]unstructured.Unstructured, error) {
+	secrets, err := client.Resource(secretsResource).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+		FieldSelector: fields.OneTermEqualSelector("type", secretType).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Items, nil
+}
+
+// latestRevisions picks the secret of the latest revision of every release
+// from the name and version labels.
+func latestRevisions(secrets []unstructured.Unstructured, logger *logrus.Entry) map[string]*unstructured.Unstructured {
+	latest := make(map[string]*unstructured.Unstructured)
+	versions := make(map[string]int)
+	for i := range secrets {
+		secret := &secrets[i]
+		secretLabels := secret.GetLabels()
+		version, err := strconv.Atoi(secretLabels["version"])
+		if err != nil || secretLabels["name"] == "" {
+			logger.
+				WithField("secret", secret.GetNamespace()+"/"+secret.GetName()).
+				Warn("Skipping Helm release secret without name and version labels")
+			continue
+		}
+		key := secret.GetNamespace() + "/" + secretLabels["name"]
+		if _, found := latest[key]; !found || version > versions[key] {
+			latest[key] = secret
+			versions[key] = version
+		}
+	}
+	return latest
+}
+
+func decodeSecret(secret *unstructured.Unstructured) (*release, error) {
+	data, _, err := unstructured.NestedString(secret.Object, "data", "release")
+	if err != nil {
+		return nil, err
+	}
+	r, err := decodeRelease(data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode release secret %s/%s: %w", secret.GetNamespace(), secret.GetName(), err)
+	}
+	if r.Namespace == "" {
+		r.Namespace = secret.GetNamespace()
+	}
+	return r, nil
+}
+
+// decodeRelease decodes the release field of a secret: base64 of the secret
+// data, holding base64 of the record, gzipped by Helm unless it's too old.
+func decodeRelease(data string) (*release, error) {
+	encoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(b, gzipMagic) {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		if b, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+	r := &release{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// mergeValues merges values into the defaults of a chart, as Helm does when
+// rendering. A null value removes a default.
+func mergeValues(defaults, values map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(values))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range values {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		valueMap, isMap := v.(map[string]interface{})
+		defaultMap, isDefaultMap := merged[k].(map[string]interface{})
+		if isMap && isDefaultMap {
+			merged[k] = mergeValues(defaultMap, valueMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
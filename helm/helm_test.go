@@ -0,0 +1,142 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+// releaseSecret encodes a release record the way Helm stores it.
+func releaseSecret(t *testing.T, name string, revision int, status string, chartVersion string) *unstructured.Unstructured {
+	record, err := json.Marshal(map[string]interface{}{
+		"name":      name,
+		"namespace": "shop",
+		"version":   revision,
+		"info": map[string]interface{}{
+			"first_deployed": "2023-05-01T10:00:00Z",
+			"last_deployed":  fmt.Sprintf("2023-05-0%dT10:00:00Z", revision),
+			"deleted":        "",
+			"status":         status,
+			"notes":          "Visit http://web.shop",
+		},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "version": chartVersion, "appVersion": "2.4.0"},
+			"values": map[string]interface{}{
+				"replicaCount": 1,
+				"image":        map[string]interface{}{"repository": "web", "tag": "latest"},
+				"debug":        true,
+			},
+		},
+		"config":   map[string]interface{}{"image": map[string]interface{}{"tag": "2.4.0"}, "debug": nil},
+		"manifest": "---\n# Source: web/templates/deployment.yaml\nkind: Deployment\n",
+	})
+	assert.NoError(t, err)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err = w.Write(record)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	data := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString(gz.Bytes())))
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			"namespace": "shop",
+			"labels":    map[string]interface{}{"owner": "helm", "name": name, "status": status, "version": fmt.Sprint(revision)},
+		},
+		"type": secretType,
+		"data": map[string]interface{}{"release": data},
+	}}
+}
+
+func TestReleases(t *testing.T) {
+	// a secret which isn't a release record is skipped
+	broken := releaseSecret(t, "db", 1, "deployed", "0.1.0")
+	assert.NoError(t, unstructured.SetNestedField(broken.Object, "bm90IGEgcmVjb3Jk", "data", "release"))
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		releaseSecret(t, "web", 1, "superseded", "1.0.0"),
+		releaseSecret(t, "web", 2, "deployed", "1.1.0"),
+		releaseSecret(t, "api", 1, "failed", "0.3.0"),
+		broken,
+	)
+	ctx := context.Background()
+	logger := logrus.NewEntry(logrus.New())
+
+	releases, err := List(ctx, client, "shop", logger)
+	assert.NoError(t, err)
+	assert.Len(t, releases, 2)
+	assert.Equal(t, "api", releases[0].Name)
+	assert.Equal(t, "web", releases[1].Name)
+	assert.Equal(t, 2, releases[1].Revision)
+	assert.Equal(t, "web-1.1.0", releases[1].Chart)
+	assert.Equal(t, "deployed", releases[1].Status)
+	assert.Equal(t, "2023-05-02T10:00:00Z", releases[1].Updated.Format("2006-01-02T15:04:05Z07:00"))
+
+	history, err := History(ctx, client, "shop", "web", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, []int{history[0].Revision, history[1].Revision})
+	assert.Equal(t, "superseded", history[0].Status)
+
+	_, err = History(ctx, client, "shop", "cache", logger)
+	assert.ErrorIs(t, err, ErrReleaseNotFound)
+
+	release, err := Get(ctx, client, "shop", "web", 0, false, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, release.Revision)
+	assert.Equal(t, "Visit http://web.shop", release.Notes)
+	assert.Contains(t, release.Manifest, "kind: Deployment")
+	assert.Equal(t, map[string]interface{}{"image": map[string]interface{}{"tag": "2.4.0"}, "debug": nil}, release.Values)
+
+	release, err = Get(ctx, client, "shop", "web", 1, true, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", release.ChartVersion)
+	assert.Equal(t, map[string]interface{}{
+		"replicaCount": float64(1),
+		"image":        map[string]interface{}{"repository": "web", "tag": "2.4.0"},
+	}, release.Values)
+
+	_, err = Get(ctx, client, "shop", "web", 5, false, logger)
+	assert.ErrorIs(t, err, ErrReleaseNotFound)
+
+	history, err = History(ctx, client, "shop", "db", logger)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestDecodeRelease_Uncompressed(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte(`{"name":"web","version":3}`))))
+	r, err := decodeRelease(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "web", r.Name)
+	assert.Equal(t, 3, r.Version)
+
+	_, err = decodeRelease("not base64")
+	assert.Error(t, err)
+}
+
+func TestLatestRevisions(t *testing.T) {
+	secrets := []unstructured.Unstructured{
+		*releaseSecret(t, "web", 9, "superseded", "1.0.0"),
+		*releaseSecret(t, "web", 10, "deployed", "1.1.0"),
+		*releaseSecret(t, "api", 1, "deployed", "0.3.0"),
+	}
+	unlabeled := releaseSecret(t, "cache", 1, "deployed", "0.1.0")
+	unlabeled.SetLabels(map[string]string{"owner": "helm"})
+	secrets = append(secrets, *unlabeled)
+
+	latest := latestRevisions(secrets, logrus.NewEntry(logrus.New()))
+	assert.Len(t, latest, 2)
+	assert.Equal(t, "sh.helm.release.v1.web.v10", latest["shop/web"].GetName())
+	assert.Equal(t, "sh.helm.release.v1.api.v1", latest["shop/api"].GetName())
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+
+	// clusterScoped replaces the namespace in the path of cluster scoped objects
+	clusterScoped = "_"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ObjectRef identifies the object a revision belongs to.
+type ObjectRef struct {
+	Context   string
+	Resource  schema.GroupVersionResource
+	Namespace string
+	Name      string
+}
+
+// Revision is the state of an object before kexp mutated it.
+type Revision struct {
+	ID         string                     `json:"id"`
+	RequestID  string                     `json:"requestId"`
+	Context    string                     `json:"context"`
+	ClusterUID string                     `json:"clusterUID"`
+	Operation  string                     `json:"operation"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Object     *unstructured.Unstructured `json:"object"`
+}
+
+// Store keeps revisions on disk, one JSON file per revision in a directory
+// per object.
+type Store struct {
+	mux sync.Mutex
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("couldn't create history directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Record stores a revision, its ID is derived from its timestamp.
+func (s *Store) Record(ref ObjectRef, rev Revision) (*Revision, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if rev.Timestamp.IsZero() {
+		rev.Timestamp = time.Now()
+	}
+	rev.Timestamp = rev.Timestamp.UTC()
+	rev.ID = fmt.Sprintf("%019d", rev.Timestamp.UnixNano())
+
+	dir := s.objectDir(ref)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("couldn't create object history directory: %w", err)
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, rev.ID+".json"), data, 0o600); err != nil {
+		return nil, fmt.Errorf("couldn't write revision: %w", err)
+	}
+	return &rev, nil
+}
+
+// List returns the revisions of an object, newest first.
+func (s *Store) List(ref ObjectRef) ([]Revision, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entries, err := os.ReadDir(s.objectDir(ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		rev, err := s.read(ref, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ID > revisions[j].ID
+	})
+	return revisions, nil
+}
+
+// Get returns a single revision of an object.
+func (s *Store) Get(ref ObjectRef, id string) (*Revision, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.read(ref, id)
+}
+
+func (s *Store) read(ref ObjectRef, id string) (*Revision, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrRevisionNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.objectDir(ref), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rev := &Revision{}
+	if err := json.Unmarshal(data, rev); err != nil {
+		return nil, fmt.Errorf("couldn't decode revision %s: %w", id, err)
+	}
+	return rev, nil
+}
+
+func (s *Store) objectDir(ref ObjectRef) string {
+	group := ref.Resource.Group
+	if group == "" {
+		group = "core"
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = clusterScoped
+	}
+	return filepath.Join(
+		s.dir,
+		url.PathEscape(ref.Context),
+		url.PathEscape(group),
+		url.PathEscape(ref.Resource.Resource),
+		url.PathEscape(namespace),
+		url.PathEscape(ref.Name),
+	)
+}
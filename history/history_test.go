@@ -0,0 +1,63 @@
+package history_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/history"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"testing"
+	"time"
+)
+
+func TestStore_RecordAndList(t *testing.T) {
+	store, err := history.NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	ref := history.ObjectRef{
+		Context:   "arn:aws:eks:eu-west-1:123456789012:cluster/shop",
+		Resource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Namespace: "shop",
+		Name:      "web",
+	}
+	startAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, replicas := range []int64{1, 2} {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}}
+		_, err := store.Record(ref, history.Revision{
+			RequestID: "req",
+			Context:   ref.Context,
+			Operation: history.OperationUpdate,
+			Timestamp: startAt.Add(time.Duration(i) * time.Minute),
+			Object:    obj,
+		})
+		assert.NoError(t, err)
+	}
+
+	revisions, err := store.List(ref)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	replicas, _, _ := unstructured.NestedInt64(revisions[0].Object.Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas, "newest revision comes first")
+
+	rev, err := store.Get(ref, revisions[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "req", rev.RequestID)
+	assert.Equal(t, startAt, rev.Timestamp)
+}
+
+func TestStore_UnknownRevision(t *testing.T) {
+	store, err := history.NewStore(t.TempDir())
+	assert.NoError(t, err)
+	ref := history.ObjectRef{Context: "kind", Resource: schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, Name: "node-1"}
+
+	revisions, err := store.List(ref)
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	_, err = store.Get(ref, "../../etc")
+	assert.ErrorIs(t, err, history.ErrRevisionNotFound)
+}
@@ -8,11 +8,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"sync"
 )
 
-var errUnknownContext = errors.New("unknown context")
+var ErrUnknownContext = errors.New("unknown context")
 
 type ClientPool struct {
 	mux      sync.RWMutex
@@ -68,7 +69,7 @@ func (p *ClientPool) SetCurrent(name string) error {
 		p.current = c
 		return nil
 	}
-	return errUnknownContext
+	return ErrUnknownContext
 }
 
 func (p *ClientPool) CurrentContext() *Context {
@@ -91,7 +92,7 @@ func (p *ClientPool) Context(name string) (*Context, error) {
 	if c, found := p.contexts[name]; found {
 		return c, nil
 	}
-	return nil, errUnknownContext
+	return nil, ErrUnknownContext
 }
 
 type Context struct {
@@ -105,6 +106,7 @@ type Context struct {
 	config          *rest.Config
 	discoveryClient discovery.DiscoveryInterface
 	dynamicClient   dynamic.Interface
+	clientset       kubernetes.Interface
 }
 
 func (c *Context) DiscoveryClient() (discovery.DiscoveryInterface, error) {
@@ -133,6 +135,27 @@ func (c *Context) DynamicClient() (dynamic.Interface, error) {
 	return c.dynamicClient, nil
 }
 
+// Clientset returns the typed client of the context, for the calls the
+// dynamic client doesn't cover like logs or evictions.
+func (c *Context) Clientset() (kubernetes.Interface, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.clientset == nil {
+		client, err := kubernetes.NewForConfig(c.config)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create clientset for given config: %w", err)
+		}
+		c.clientset = client
+	}
+	return c.clientset, nil
+}
+
+// RESTConfig returns a copy of the REST config of the context, for the
+// clients which can't be served by the discovery and dynamic ones.
+func (c *Context) RESTConfig() *rest.Config {
+	return rest.CopyConfig(c.config)
+}
+
 func (c *Context) Name() string {
 	return c.name
 }
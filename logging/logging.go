@@ -10,9 +10,13 @@ type loggingContextKey int
 const KeyRequestID loggingContextKey = iota
 
 func WithRequestID(cxt context.Context, logger *logrus.Entry) *logrus.Entry {
+	return logger.WithField("request_id", RequestID(cxt))
+}
+
+func RequestID(cxt context.Context) string {
 	rid, ok := cxt.Value(KeyRequestID).(string)
 	if !ok {
 		rid = "none"
 	}
-	return logger.WithField("request_id", rid)
+	return rid
 }
@@ -9,13 +9,30 @@ import (
 	"k8s-explore/api"
 	restenvironments "k8s-explore/api/rest/environment"
 	restkubecontexts "k8s-explore/api/rest/kube/contexts"
+	restkubemanifests "k8s-explore/api/rest/kube/manifests"
+	restkubemetrics "k8s-explore/api/rest/kube/metrics"
+	restkubenamespaces "k8s-explore/api/rest/kube/namespaces"
+	restkubenodes "k8s-explore/api/rest/kube/nodes"
 	restkubeobjects "k8s-explore/api/rest/kube/objects"
+	restkubepods "k8s-explore/api/rest/kube/pods"
+	restkubeportforwards "k8s-explore/api/rest/kube/portforwards"
+	restkubereleases "k8s-explore/api/rest/kube/releases"
 	restkuberesources "k8s-explore/api/rest/kube/resources"
+	restkuberollouts "k8s-explore/api/rest/kube/rollouts"
 	"k8s-explore/api/stream"
 	streamrpc "k8s-explore/api/stream/rpc"
+	streamkubeevents "k8s-explore/api/stream/rpc/kube/events"
+	streamkubenodes "k8s-explore/api/stream/rpc/kube/nodes"
 	streamkubeobjects "k8s-explore/api/stream/rpc/kube/objects"
+	streamkubepods "k8s-explore/api/stream/rpc/kube/pods"
+	streamkuberollouts "k8s-explore/api/stream/rpc/kube/rollouts"
+	"k8s-explore/history"
 	"k8s-explore/kubeclient"
+	"k8s-explore/openapi"
+	"k8s-explore/portforward"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -29,8 +46,9 @@ var (
 
 type flagpole struct {
 	*genericclioptions.ConfigFlags
-	host string
-	port string
+	host       string
+	port       string
+	historyDir string
 }
 
 func main() {
@@ -47,6 +65,7 @@ func main() {
 	flags.AddFlags(cmd.PersistentFlags())
 	cmd.PersistentFlags().StringVar(&flags.host, "host", "127.0.0.1", "Listening host")
 	cmd.PersistentFlags().StringVar(&flags.port, "port", "5173", "Listening port")
+	cmd.PersistentFlags().StringVar(&flags.historyDir, "history-dir", defaultHistoryDir(), "Directory of the object change history")
 
 	if err := cmd.Execute(); err != nil {
 		logrus.WithError(err).Fatal("Command failed")
@@ -72,15 +91,27 @@ func run(flags *flagpole) func(cmd *cobra.Command, args []string) {
 		kubeContextsHandler := restkubecontexts.NewHandler(kubeClientPool, logrus.NewEntry(logrus.StandardLogger()))
 		kubeContextsv1 := router.Group("/api/kube/v1/contexts")
 		kubeContextsv1.GET("/", kubeContextsHandler.List)
+		schemaCache := openapi.NewCache()
 		kubeResourcesHandler := restkuberesources.NewHandler(
 			kubeClientPool,
+			schemaCache,
 			logrus.NewEntry(logrus.StandardLogger()),
 		)
 		kubeResourcesv1 := router.Group("/api/kube/v1/contexts/:ctx/resources")
 		kubeResourcesv1.GET("/", kubeResourcesHandler.List)
+		kubeSchemasv1 := router.Group("/api/kube/v1/contexts/:ctx/schemas")
+		kubeSchemasv1.GET("/:group/:version/:kind", kubeResourcesHandler.Explain)
 
+		historyStore, err := history.NewStore(flags.historyDir)
+		if err != nil {
+			logrus.
+				WithError(err).
+				Fatal("Could not initialize object history store")
+		}
 		kubeObjectsHandler := restkubeobjects.NewHandler(
 			kubeClientPool,
+			historyStore,
+			schemaCache,
 			logrus.NewEntry(logrus.StandardLogger()),
 		)
 		kubeObjectsv1 := router.Group("/api/kube/v1/contexts/:ctx/resources")
@@ -88,10 +119,108 @@ func run(flags *flagpole) func(cmd *cobra.Command, args []string) {
 		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/", kubeObjectsHandler.List)
 		kubeObjectsv1.GET("/:group/:version/:resource/:name/", kubeObjectsHandler.Get)
 		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/:name/", kubeObjectsHandler.Get)
+		kubeObjectsv1.POST("/:group/:version/:resource/", kubeObjectsHandler.Create)
+		kubeObjectsv1.POST("/:group/:version/namespaces/:namespace/:resource/", kubeObjectsHandler.Create)
 		kubeObjectsv1.PUT("/:group/:version/:resource/:name/", kubeObjectsHandler.Update)
 		kubeObjectsv1.PUT("/:group/:version/namespaces/:namespace/:resource/:name/", kubeObjectsHandler.Update)
+		kubeObjectsv1.POST("/:group/:version/:resource/validate", kubeObjectsHandler.Validate)
+		kubeObjectsv1.POST("/:group/:version/namespaces/:namespace/:resource/validate", kubeObjectsHandler.Validate)
+		kubeObjectsv1.POST("/:group/:version/:resource/:name/diff", kubeObjectsHandler.Diff)
+		kubeObjectsv1.POST("/:group/:version/namespaces/:namespace/:resource/:name/diff", kubeObjectsHandler.Diff)
+		kubeObjectsv1.PATCH("/:group/:version/:resource/:name/", kubeObjectsHandler.Patch)
+		kubeObjectsv1.PATCH("/:group/:version/namespaces/:namespace/:resource/:name/", kubeObjectsHandler.Patch)
+		kubeObjectsv1.GET("/:group/:version/:resource/:name/graph", kubeObjectsHandler.Graph)
+		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/:name/graph", kubeObjectsHandler.Graph)
+		kubeObjectsv1.GET("/:group/:version/:resource/:name/history", kubeObjectsHandler.History)
+		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/:name/history", kubeObjectsHandler.History)
+		kubeObjectsv1.POST("/:group/:version/:resource/:name/history/:revision/restore", kubeObjectsHandler.Restore)
+		kubeObjectsv1.POST("/:group/:version/namespaces/:namespace/:resource/:name/history/:revision/restore", kubeObjectsHandler.Restore)
+		kubeObjectsv1.GET("/:group/:version/:resource/:name/:subresource", kubeObjectsHandler.Get)
+		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/:name/:subresource", kubeObjectsHandler.Get)
+		kubeObjectsv1.PUT("/:group/:version/:resource/:name/:subresource", kubeObjectsHandler.Update)
+		kubeObjectsv1.PUT("/:group/:version/namespaces/:namespace/:resource/:name/:subresource", kubeObjectsHandler.Update)
+		kubeObjectsv1.PATCH("/:group/:version/:resource/:name/:subresource", kubeObjectsHandler.Patch)
+		kubeObjectsv1.PATCH("/:group/:version/namespaces/:namespace/:resource/:name/:subresource", kubeObjectsHandler.Patch)
+		kubeObjectsv1.DELETE("/:group/:version/:resource/", kubeObjectsHandler.DeleteCollection)
+		kubeObjectsv1.DELETE("/:group/:version/namespaces/:namespace/:resource/", kubeObjectsHandler.DeleteCollection)
 		kubeObjectsv1.DELETE("/:group/:version/:resource/:name/", kubeObjectsHandler.Delete)
 		kubeObjectsv1.DELETE("/:group/:version/namespaces/:namespace/:resource/:name/", kubeObjectsHandler.Delete)
+		// namespaces share their paths with the namespaced objects, whose
+		// routes win, they are routed explicitly
+		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/", restkubeobjects.NamespaceObject(kubeObjectsHandler.Get, ""))
+		kubeObjectsv1.PUT("/:group/:version/namespaces/:namespace/", restkubeobjects.NamespaceObject(kubeObjectsHandler.Update, ""))
+		kubeObjectsv1.PATCH("/:group/:version/namespaces/:namespace/", restkubeobjects.NamespaceObject(kubeObjectsHandler.Patch, ""))
+		kubeObjectsv1.DELETE("/:group/:version/namespaces/:namespace/", restkubeobjects.NamespaceObject(kubeObjectsHandler.Delete, ""))
+		kubeObjectsv1.GET("/:group/:version/namespaces/:namespace/status", restkubeobjects.NamespaceObject(kubeObjectsHandler.Get, "status"))
+		kubeObjectsv1.PUT("/:group/:version/namespaces/:namespace/status", restkubeobjects.NamespaceObject(kubeObjectsHandler.Update, "status"))
+		kubeObjectsv1.PATCH("/:group/:version/namespaces/:namespace/status", restkubeobjects.NamespaceObject(kubeObjectsHandler.Patch, "status"))
+		kubeAllObjectsv1 := router.Group("/api/kube/v1/resources")
+		kubeAllObjectsv1.GET("/:group/:version/:resource/", kubeObjectsHandler.ListAll)
+		kubeAllObjectsv1.GET("/:group/:version/namespaces/:namespace/:resource/", kubeObjectsHandler.ListAll)
+		kubeManifestsHandler := restkubemanifests.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeManifestsv1 := router.Group("/api/kube/v1/contexts/:ctx/apply")
+		kubeManifestsv1.POST("/", kubeManifestsHandler.Apply)
+		kubeNamespacesHandler := restkubenamespaces.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeNamespacesv1 := router.Group("/api/kube/v1/contexts/:ctx/namespaces")
+		kubeNamespacesv1.GET("/:namespace/export", kubeNamespacesHandler.Export)
+		kubePodsHandler := restkubepods.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeNamespacesv1.GET("/:namespace/pods/:name/files", kubePodsHandler.Download)
+		kubeNamespacesv1.PUT("/:namespace/pods/:name/files", kubePodsHandler.Upload)
+		kubeRolloutsHandler := restkuberollouts.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeNamespacesv1.GET("/:namespace/rollouts/:resource/:name/status", kubeRolloutsHandler.Status)
+		kubeNamespacesv1.GET("/:namespace/rollouts/:resource/:name/history", kubeRolloutsHandler.History)
+		kubeNamespacesv1.GET("/:namespace/rollouts/:resource/:name/history/diff", kubeRolloutsHandler.Diff)
+		kubeNamespacesv1.POST("/:namespace/rollouts/:resource/:name/restart", kubeRolloutsHandler.Restart)
+		kubeNamespacesv1.POST("/:namespace/rollouts/:resource/:name/pause", kubeRolloutsHandler.Pause)
+		kubeNamespacesv1.POST("/:namespace/rollouts/:resource/:name/resume", kubeRolloutsHandler.Resume)
+		kubeNamespacesv1.POST("/:namespace/rollouts/:resource/:name/undo", kubeRolloutsHandler.Undo)
+		kubeReleasesHandler := restkubereleases.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeReleasesv1 := router.Group("/api/kube/v1/contexts/:ctx/releases")
+		kubeReleasesv1.GET("/", kubeReleasesHandler.List)
+		kubeNamespacesv1.GET("/:namespace/releases/", kubeReleasesHandler.List)
+		kubeNamespacesv1.GET("/:namespace/releases/:name", kubeReleasesHandler.Get)
+		kubeNamespacesv1.GET("/:namespace/releases/:name/history", kubeReleasesHandler.History)
+		kubeNodesHandler := restkubenodes.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeNodesv1 := router.Group("/api/kube/v1/contexts/:ctx/nodes")
+		kubeNodesv1.POST("/:name/cordon", kubeNodesHandler.Cordon)
+		kubeNodesv1.POST("/:name/uncordon", kubeNodesHandler.Uncordon)
+		kubeMetricsHandler := restkubemetrics.NewHandler(
+			kubeClientPool,
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeMetricsv1 := router.Group("/api/kube/v1/contexts/:ctx/metrics")
+		kubeMetricsv1.GET("/nodes/", kubeMetricsHandler.Nodes)
+		kubeMetricsv1.GET("/nodes/:name", kubeMetricsHandler.Node)
+		kubeMetricsv1.GET("/pods/", kubeMetricsHandler.Pods)
+		kubeMetricsv1.GET("/namespaces/:namespace/pods/", kubeMetricsHandler.Pods)
+		kubeMetricsv1.GET("/namespaces/:namespace/pods/:name", kubeMetricsHandler.Pod)
+		kubePortForwardsHandler := restkubeportforwards.NewHandler(
+			portforward.NewManager(kubeClientPool),
+			logrus.NewEntry(logrus.StandardLogger()),
+		)
+		kubeContextPortForwardsv1 := router.Group("/api/kube/v1/contexts/:ctx/portforwards")
+		kubeContextPortForwardsv1.POST("/", kubePortForwardsHandler.Start)
+		kubePortForwardsv1 := router.Group("/api/kube/v1/portforwards")
+		kubePortForwardsv1.GET("/", kubePortForwardsHandler.List)
+		kubePortForwardsv1.DELETE("/:id", kubePortForwardsHandler.Stop)
 		// env handler
 		environmentHandler := restenvironments.NewHandler(kubeClientPool,
 			logrus.NewEntry(logrus.StandardLogger()))
@@ -103,6 +232,26 @@ func run(flags *flagpole) func(cmd *cobra.Command, args []string) {
 			streamkubeobjects.Watch,
 			streamkubeobjects.NewWatchHandler(kubeClientPool),
 		)
+		rpcCallDispatcher.RegisterCallHandler(
+			streamkubepods.Logs,
+			streamkubepods.NewLogsHandler(kubeClientPool),
+		)
+		rpcCallDispatcher.RegisterCallHandler(
+			streamkubepods.Exec,
+			streamkubepods.NewExecHandler(kubeClientPool),
+		)
+		rpcCallDispatcher.RegisterCallHandler(
+			streamkubeevents.Watch,
+			streamkubeevents.NewWatchHandler(kubeClientPool),
+		)
+		rpcCallDispatcher.RegisterCallHandler(
+			streamkuberollouts.Status,
+			streamkuberollouts.NewStatusHandler(kubeClientPool),
+		)
+		rpcCallDispatcher.RegisterCallHandler(
+			streamkubenodes.Drain,
+			streamkubenodes.NewDrainHandler(kubeClientPool),
+		)
 		streamHandler := stream.NewHandler(logrus.NewEntry(logrus.StandardLogger()))
 		streamHandler.RegisterMessageHandler(streamrpc.MessageTypeCall, rpcCallDispatcher)
 		streamv1 := router.Group("/api/stream/v1")
@@ -114,6 +263,14 @@ func run(flags *flagpole) func(cmd *cobra.Command, args []string) {
 	}
 }
 
+func defaultHistoryDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "kexp", "history")
+	}
+	return filepath.Join(home, ".kexp", "history")
+}
+
 func initKubeClientPool(ctx context.Context, flags *flagpole) (*kubeclient.ClientPool, error) {
 	rawConfig, err := flags.ToRawKubeConfigLoader().RawConfig()
 	if err != nil {
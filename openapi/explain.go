@@ -0,0 +1,100 @@
+package openapi
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"strings"
+)
+
+// Field is a property of the explained schema.
+type Field struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Required    bool     `json:"required"`
+	Enum        []string `json:"enum,omitempty"`
+}
+
+// Explanation describes a kind or one of its fields, like kubectl explain.
+type Explanation struct {
+	Group       string  `json:"group"`
+	Version     string  `json:"version"`
+	Kind        string  `json:"kind"`
+	Path        string  `json:"path,omitempty"`
+	Type        string  `json:"type"`
+	Description string  `json:"description,omitempty"`
+	Fields      []Field `json:"fields"`
+}
+
+// Explain describes the field at the given dot separated path of a kind,
+// the kind itself for an empty path. Arrays are walked through
+// transparently: spec.containers describes the container schema.
+func (d *Document) Explain(gvk schema.GroupVersionKind, path string) (*Explanation, error) {
+	s, err := d.KindSchema(gvk)
+	if err != nil {
+		return nil, err
+	}
+	description := s.Description
+	typeName := d.typeName(s)
+	if path != "" {
+		for _, name := range strings.Split(path, ".") {
+			parent := d.elementSchema(s)
+			property, found := parent.Properties[name]
+			if !found {
+				return nil, fmt.Errorf("%w: field %q doesn't exist in %s", ErrNotFound, path, gvk.Kind)
+			}
+			s = &property
+			// the description next to a reference is more specific
+			description = property.Description
+			if description == "" {
+				description = d.resolve(s).Description
+			}
+			typeName = d.typeName(s)
+		}
+	}
+
+	e := &Explanation{
+		Group:       gvk.Group,
+		Version:     gvk.Version,
+		Kind:        gvk.Kind,
+		Path:        path,
+		Type:        typeName,
+		Description: description,
+		Fields:      []Field{},
+	}
+	element := d.elementSchema(s)
+	for _, name := range sortedPropertyNames(element) {
+		property := element.Properties[name]
+		fieldDescription := property.Description
+		if fieldDescription == "" {
+			fieldDescription = d.resolve(&property).Description
+		}
+		e.Fields = append(e.Fields, Field{
+			Name:        name,
+			Type:        d.typeName(&property),
+			Description: fieldDescription,
+			Required:    isRequired(element, name),
+			Enum:        enumValues(d.resolve(&property)),
+		})
+	}
+	return e, nil
+}
+
+// elementSchema resolves a schema down to the object holding the fields,
+// going through array items.
+func (d *Document) elementSchema(s *spec.Schema) *spec.Schema {
+	s = d.resolve(s)
+	for s.Type.Contains("array") && s.Items != nil && s.Items.Schema != nil {
+		s = d.resolve(s.Items.Schema)
+	}
+	return s
+}
+
+func enumValues(s *spec.Schema) []string {
+	var values []string
+	for _, v := range s.Enum {
+		values = append(values, fmt.Sprint(v))
+	}
+	return values
+}
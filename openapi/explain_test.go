@@ -0,0 +1,128 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/openapi"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"testing"
+)
+
+const deploymentDocument = `{
+  "openapi": "3.0.0",
+  "info": {"title": "Kubernetes", "version": "v1.28.0"},
+  "paths": {},
+  "components": {"schemas": {
+    "io.k8s.api.apps.v1.Deployment": {
+      "description": "Deployment enables declarative updates for Pods and ReplicaSets.",
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string", "description": "APIVersion defines the versioned schema."},
+        "kind": {"type": "string", "description": "Kind is a string value."},
+        "spec": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.apps.v1.DeploymentSpec"}], "description": "Specification of the desired behavior."}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "apps", "kind": "Deployment", "version": "v1"}]
+    },
+    "io.k8s.api.apps.v1.DeploymentSpec": {
+      "description": "DeploymentSpec is the specification of the desired behavior of the Deployment.",
+      "type": "object",
+      "required": ["selector", "template"],
+      "properties": {
+        "replicas": {"type": "integer", "format": "int32", "description": "Number of desired pods."},
+        "selector": {"type": "object", "description": "Label selector for pods."},
+        "template": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.PodTemplateSpec"}], "description": "Template describes the pods that will be created."}
+      }
+    },
+    "io.k8s.api.core.v1.PodTemplateSpec": {
+      "type": "object",
+      "properties": {
+        "spec": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.PodSpec"}]}
+      }
+    },
+    "io.k8s.api.core.v1.PodSpec": {
+      "description": "PodSpec is a description of a pod.",
+      "type": "object",
+      "required": ["containers"],
+      "properties": {
+        "containers": {"type": "array", "items": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.Container"}]}, "description": "List of containers belonging to the pod."},
+        "nodeSelector": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "io.k8s.api.core.v1.Container": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"},
+        "imagePullPolicy": {"type": "string", "enum": ["Always", "IfNotPresent", "Never"]},
+        "ports": {"type": "array", "items": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.ContainerPort"}]}}
+      }
+    },
+    "io.k8s.api.core.v1.ContainerPort": {
+      "type": "object",
+      "properties": {
+        "containerPort": {"type": "integer"},
+        "targetPort": {"allOf": [{"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.util.intstr.IntOrString"}]}
+      }
+    },
+    "io.k8s.apimachinery.pkg.util.intstr.IntOrString": {
+      "type": "string",
+      "format": "int-or-string"
+    }
+  }}
+}`
+
+var deploymentGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+func deploymentDoc(t *testing.T) *openapi.Document {
+	openAPI := &spec3.OpenAPI{}
+	assert.NoError(t, json.Unmarshal([]byte(deploymentDocument), openAPI))
+	return openapi.NewDocument(openAPI)
+}
+
+func TestDocument_ExplainKind(t *testing.T) {
+	e, err := deploymentDoc(t).Explain(deploymentGVK, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Object", e.Type)
+	assert.Equal(t, "Deployment enables declarative updates for Pods and ReplicaSets.", e.Description)
+	assert.Equal(t, []openapi.Field{
+		{Name: "apiVersion", Type: "string", Description: "APIVersion defines the versioned schema."},
+		{Name: "kind", Type: "string", Description: "Kind is a string value."},
+		{Name: "spec", Type: "Object", Description: "Specification of the desired behavior."},
+	}, e.Fields)
+}
+
+func TestDocument_ExplainPath(t *testing.T) {
+	doc := deploymentDoc(t)
+
+	e, err := doc.Explain(deploymentGVK, "spec.template.spec")
+	assert.NoError(t, err)
+	assert.Equal(t, "PodSpec is a description of a pod.", e.Description)
+	assert.Equal(t, []openapi.Field{
+		{Name: "containers", Type: "[]Object", Description: "List of containers belonging to the pod.", Required: true},
+		{Name: "nodeSelector", Type: "map[string]string"},
+	}, e.Fields)
+
+	e, err = doc.Explain(deploymentGVK, "spec.template.spec.containers")
+	assert.NoError(t, err)
+	assert.Equal(t, "[]Object", e.Type)
+	assert.Equal(t, []openapi.Field{
+		{Name: "imagePullPolicy", Type: "string", Enum: []string{"Always", "IfNotPresent", "Never"}},
+		{Name: "name", Type: "string", Required: true},
+		{Name: "ports", Type: "[]Object"},
+	}, e.Fields)
+
+	e, err = doc.Explain(deploymentGVK, "spec.template.spec.containers.ports.targetPort")
+	assert.NoError(t, err)
+	assert.Equal(t, "IntOrString", e.Type)
+}
+
+func TestDocument_ExplainNotFound(t *testing.T) {
+	doc := deploymentDoc(t)
+
+	_, err := doc.Explain(deploymentGVK, "spec.unknown")
+	assert.ErrorIs(t, err, openapi.ErrNotFound)
+
+	_, err = doc.Explain(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, "")
+	assert.ErrorIs(t, err, openapi.ErrNotFound)
+}
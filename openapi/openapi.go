@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"k8s-explore/kubeclient"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	schemaRefPrefix = "#/components/schemas/"
+	extensionGVK    = "x-kubernetes-group-version-kind"
+	extensionIntStr = "x-kubernetes-int-or-string"
+	formatIntStr    = "int-or-string"
+
+	extensionPreserveUnknown = "x-kubernetes-preserve-unknown-fields"
+
+	// documentTTL bounds how long a document is used, CRD changes which
+	// don't go through Invalidate show up after it
+	documentTTL = 5 * time.Minute
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Cache keeps the OpenAPI v3 documents of the clusters, one per group
+// version, for documentTTL. Contexts pointing to the same cluster share the
+// documents, the ones of a cluster without UID aren't cached.
+type Cache struct {
+	mux  sync.Mutex
+	docs map[string]cachedDocument
+	now  func() time.Time
+}
+
+type cachedDocument struct {
+	doc     *Document
+	fetched time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{docs: make(map[string]cachedDocument), now: time.Now}
+}
+
+// Document returns the OpenAPI v3 document describing a group version,
+// fetching it through the discovery client of the context on a cache miss.
+func (c *Cache) Document(kctx *kubeclient.Context, gv schema.GroupVersion) (*Document, error) {
+	clusterUID := kctx.ClusterUID()
+	key := clusterUID + "/" + documentPath(gv)
+	if clusterUID != "" {
+		if doc, found := c.cached(key); found {
+			return doc, nil
+		}
+	}
+
+	client, err := kctx.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := client.OpenAPIV3().Paths()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list OpenAPI v3 documents: %w", err)
+	}
+	gvDoc, found := paths[documentPath(gv)]
+	if !found {
+		return nil, fmt.Errorf("%w: no OpenAPI v3 document for %s", ErrNotFound, gv)
+	}
+	data, err := gvDoc.Schema("application/json")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch OpenAPI v3 document for %s: %w", gv, err)
+	}
+	openAPI := &spec3.OpenAPI{}
+	if err := json.Unmarshal(data, openAPI); err != nil {
+		return nil, fmt.Errorf("couldn't decode OpenAPI v3 document for %s: %w", gv, err)
+	}
+	doc := &Document{openAPI: openAPI}
+
+	if clusterUID != "" {
+		c.mux.Lock()
+		c.docs[key] = cachedDocument{doc: doc, fetched: c.now()}
+		c.mux.Unlock()
+	}
+	return doc, nil
+}
+
+func (c *Cache) cached(key string) (*Document, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	cached, found := c.docs[key]
+	if !found {
+		return nil, false
+	}
+	if c.now().Sub(cached.fetched) > documentTTL {
+		delete(c.docs, key)
+		return nil, false
+	}
+	return cached.doc, true
+}
+
+// Invalidate drops the documents of a cluster, e.g. after CRD changes.
+func (c *Cache) Invalidate(clusterUID string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for key := range c.docs {
+		if strings.HasPrefix(key, clusterUID+"/") {
+			delete(c.docs, key)
+		}
+	}
+}
+
+func documentPath(gv schema.GroupVersion) string {
+	if gv.Group == "" {
+		return "api/" + gv.Version
+	}
+	return "apis/" + gv.Group + "/" + gv.Version
+}
+
+// Document is the OpenAPI v3 document of a group version.
+type Document struct {
+	openAPI *spec3.OpenAPI
+}
+
+func NewDocument(openAPI *spec3.OpenAPI) *Document {
+	return &Document{openAPI: openAPI}
+}
+
+// KindSchema returns the schema of the given kind.
+func (d *Document) KindSchema(gvk schema.GroupVersionKind) (*spec.Schema, error) {
+	if d.openAPI.Components != nil {
+		for _, s := range d.openAPI.Components.Schemas {
+			if hasGVK(s, gvk) {
+				return s, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: no schema for %s", ErrNotFound, gvk)
+}
+
+// resolve follows the references of a schema, including the single
+// element allOf wrappers used to attach a description to a reference.
+func (d *Document) resolve(s *spec.Schema) *spec.Schema {
+	for i := 0; s != nil && i < 32; i++ {
+		if ref := s.Ref.String(); ref != "" {
+			if d.openAPI.Components == nil {
+				return s
+			}
+			target, found := d.openAPI.Components.Schemas[strings.TrimPrefix(ref, schemaRefPrefix)]
+			if !found {
+				return s
+			}
+			s = target
+			continue
+		}
+		if len(s.AllOf) == 1 && len(s.Properties) == 0 && len(s.Type) == 0 {
+			s = &s.AllOf[0]
+			continue
+		}
+		return s
+	}
+	return s
+}
+
+// typeName renders the type of a schema the way kubectl explain does.
+func (d *Document) typeName(s *spec.Schema) string {
+	if isIntOrString(s) {
+		return "IntOrString"
+	}
+	s = d.resolve(s)
+	if isIntOrString(s) {
+		return "IntOrString"
+	}
+	switch {
+	case s.Type.Contains("array"):
+		if s.Items != nil && s.Items.Schema != nil {
+			return "[]" + d.typeName(s.Items.Schema)
+		}
+		return "[]Object"
+	case len(s.Properties) > 0:
+		return "Object"
+	case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+		return "map[string]" + d.typeName(s.AdditionalProperties.Schema)
+	case s.Type.Contains("object"):
+		return "Object"
+	case len(s.Type) > 0:
+		return s.Type[0]
+	}
+	return "Object"
+}
+
+// isIntOrString matches both the CRD extension and the string format used
+// by the built-in types.
+func isIntOrString(s *spec.Schema) bool {
+	_, ok := s.Extensions[extensionIntStr]
+	return ok || s.Format == formatIntStr
+}
+
+func hasGVK(s *spec.Schema, gvk schema.GroupVersionKind) bool {
+	gvks, ok := s.Extensions[extensionGVK].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range gvks {
+		m, ok := item.(map[string]interface{})
+		if ok && m["group"] == gvk.Group && m["version"] == gvk.Version && m["kind"] == gvk.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func isRequired(s *spec.Schema, name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedPropertyNames(s *spec.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,25 @@
+package openapi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"testing"
+	"time"
+)
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCache()
+	c.now = func() time.Time { return now }
+	doc := NewDocument(&spec3.OpenAPI{})
+	c.docs["uid-1/apis/example.com/v1"] = cachedDocument{doc: doc, fetched: now}
+
+	cached, found := c.cached("uid-1/apis/example.com/v1")
+	assert.True(t, found)
+	assert.Same(t, doc, cached)
+
+	now = now.Add(documentTTL + time.Second)
+	_, found = c.cached("uid-1/apis/example.com/v1")
+	assert.False(t, found)
+	assert.Empty(t, c.docs)
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Violation types, the ones shared with the API server use its cause types.
+const (
+	ViolationRequired     = string(metav1.CauseTypeFieldValueRequired)
+	ViolationInvalidType  = string(metav1.CauseTypeFieldValueInvalid)
+	ViolationNotSupported = string(metav1.CauseTypeFieldValueNotSupported)
+	ViolationUnknownField = "FieldValueUnknown"
+)
+
+// Violation is a mismatch between an object and the schema of its kind.
+type Violation struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Validate checks an object against the schema of its kind. Unknown fields,
+// which the API server drops by default, are only reported when strict.
+func (d *Document) Validate(gvk schema.GroupVersionKind, obj map[string]interface{}, strict bool) ([]Violation, error) {
+	s, err := d.KindSchema(gvk)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{doc: d, strict: strict, violations: []Violation{}}
+	v.validate("", obj, s)
+	return v.violations, nil
+}
+
+type validator struct {
+	doc        *Document
+	strict     bool
+	violations []Violation
+}
+
+func (v *validator) report(path string, violationType string, message string) {
+	v.violations = append(v.violations, Violation{Path: path, Type: violationType, Message: message})
+}
+
+func (v *validator) validate(path string, value interface{}, s *spec.Schema) {
+	// null is how unset optional fields often come in
+	if value == nil {
+		return
+	}
+	if isIntOrString(s) {
+		v.validateIntOrString(path, value)
+		return
+	}
+	s = v.doc.resolve(s)
+	if isIntOrString(s) {
+		v.validateIntOrString(path, value)
+		return
+	}
+
+	switch {
+	case s.Type.Contains("array"):
+		items, ok := value.([]interface{})
+		if !ok {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected array, got %s", jsonType(value)))
+			return
+		}
+		if s.Items == nil || s.Items.Schema == nil {
+			return
+		}
+		for i, item := range items {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), item, s.Items.Schema)
+		}
+	case s.Type.Contains("object") || len(s.Properties) > 0 || s.AdditionalProperties != nil:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected object, got %s", jsonType(value)))
+			return
+		}
+		v.validateObject(path, fields, s)
+	case s.Type.Contains("string"):
+		str, ok := value.(string)
+		if !ok {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected string, got %s", jsonType(value)))
+			return
+		}
+		v.validateEnum(path, str, s)
+	case s.Type.Contains("integer"):
+		if !isInteger(value) {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected integer, got %s", jsonType(value)))
+		}
+	case s.Type.Contains("number"):
+		if jsonType(value) != "number" {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected number, got %s", jsonType(value)))
+		}
+	case s.Type.Contains("boolean"):
+		if _, ok := value.(bool); !ok {
+			v.report(path, ViolationInvalidType, fmt.Sprintf("expected boolean, got %s", jsonType(value)))
+		}
+	}
+}
+
+func (v *validator) validateObject(path string, fields map[string]interface{}, s *spec.Schema) {
+	for _, name := range s.Required {
+		if _, found := fields[name]; !found {
+			v.report(fieldPath(path, name), ViolationRequired, "required field is missing")
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// objects without any field definition, like the metadata of custom
+	// resources, are free-form
+	freeForm := len(s.Properties) == 0 && s.AdditionalProperties == nil
+	preserveUnknown, _ := s.Extensions[extensionPreserveUnknown].(bool)
+	for _, name := range names {
+		if property, found := s.Properties[name]; found {
+			v.validate(fieldPath(path, name), fields[name], &property)
+			continue
+		}
+		if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.Schema != nil {
+				v.validate(fieldPath(path, name), fields[name], s.AdditionalProperties.Schema)
+				continue
+			}
+			if s.AdditionalProperties.Allows {
+				continue
+			}
+		}
+		if freeForm || preserveUnknown || !v.strict {
+			continue
+		}
+		v.report(fieldPath(path, name), ViolationUnknownField, "unknown field")
+	}
+}
+
+func (v *validator) validateIntOrString(path string, value interface{}) {
+	if _, ok := value.(string); ok {
+		return
+	}
+	if !isInteger(value) {
+		v.report(path, ViolationInvalidType, fmt.Sprintf("expected integer or string, got %s", jsonType(value)))
+	}
+}
+
+func (v *validator) validateEnum(path string, value string, s *spec.Schema) {
+	if len(s.Enum) == 0 {
+		return
+	}
+	allowed := make([]string, 0, len(s.Enum))
+	for _, e := range s.Enum {
+		if fmt.Sprint(e) == value {
+			return
+		}
+		allowed = append(allowed, fmt.Sprintf("%q", e))
+	}
+	v.report(path, ViolationNotSupported, fmt.Sprintf("unsupported value %q, supported values: %s", value, strings.Join(allowed, ", ")))
+}
+
+func fieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int32, int64, float32, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,74 @@
+package openapi_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s-explore/openapi"
+	"testing"
+)
+
+func TestDocument_Validate(t *testing.T) {
+	obj := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": "3",
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":            "web",
+							"imagePullPolicy": "Sometimes",
+							"ports": []interface{}{
+								map[string]interface{}{"containerPort": int64(80), "targetPort": "http"},
+								map[string]interface{}{"containerPort": 8080.5, "targetPort": true},
+							},
+						},
+						map[string]interface{}{"image": "nginx"},
+					},
+					"nodeSelector": map[string]interface{}{"disk": int64(1)},
+				},
+			},
+			"paused": true,
+		},
+	}
+
+	violations, err := deploymentDoc(t).Validate(deploymentGVK, obj, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []openapi.Violation{
+		{Path: "spec.selector", Type: openapi.ViolationRequired, Message: "required field is missing"},
+		{Path: "spec.replicas", Type: openapi.ViolationInvalidType, Message: "expected integer, got string"},
+		{Path: "spec.template.spec.containers[0].imagePullPolicy", Type: openapi.ViolationNotSupported, Message: `unsupported value "Sometimes", supported values: "Always", "IfNotPresent", "Never"`},
+		{Path: "spec.template.spec.containers[0].ports[1].containerPort", Type: openapi.ViolationInvalidType, Message: "expected integer, got number"},
+		{Path: "spec.template.spec.containers[0].ports[1].targetPort", Type: openapi.ViolationInvalidType, Message: "expected integer or string, got boolean"},
+		{Path: "spec.template.spec.containers[1].name", Type: openapi.ViolationRequired, Message: "required field is missing"},
+		{Path: "spec.template.spec.nodeSelector.disk", Type: openapi.ViolationInvalidType, Message: "expected string, got number"},
+	}, violations)
+
+	violations, err = deploymentDoc(t).Validate(deploymentGVK, obj, true)
+	assert.NoError(t, err)
+	assert.Contains(t, violations, openapi.Violation{Path: "spec.paused", Type: openapi.ViolationUnknownField, Message: "unknown field"})
+	assert.Contains(t, violations, openapi.Violation{Path: "spec.template.spec.containers[1].image", Type: openapi.ViolationUnknownField, Message: "unknown field"})
+	assert.Len(t, violations, 9)
+}
+
+func TestDocument_ValidateValid(t *testing.T) {
+	obj := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "ports": []interface{}{map[string]interface{}{"targetPort": int64(8080)}}},
+					},
+				},
+			},
+		},
+	}
+
+	violations, err := deploymentDoc(t).Validate(deploymentGVK, obj, true)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
@@ -0,0 +1,492 @@
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"io"
+	"k8s-explore/kubeclient"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	clientportforward "k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	KindService = "service"
+	KindPod     = "pod"
+
+	StatusActive       = "Active"
+	StatusReconnecting = "Reconnecting"
+	StatusStopped      = "Stopped"
+
+	readyTimeout = 30 * time.Second
+)
+
+// retryPolicy paces the re-targeting of a forward.
+type retryPolicy struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	// goneAfter is how long the target may be missing before the forward
+	// stops, a pod of a StatefulSet comes back under the same name
+	goneAfter time.Duration
+}
+
+var defaultRetry = retryPolicy{
+	minBackoff: time.Second,
+	maxBackoff: 30 * time.Second,
+	goneAfter:  30 * time.Second,
+}
+
+var (
+	ErrForwardNotFound = errors.New("port forward not found")
+	ErrNoPod           = errors.New("no running pod")
+	ErrInvalidTarget   = errors.New("invalid port forward target")
+)
+
+// Target is what a forward points to: a port of a service or of a pod, the
+// port being a number or a name.
+type Target struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Port      string `json:"port"`
+}
+
+// Forward describes a running port forward.
+type Forward struct {
+	ID         string    `json:"id"`
+	Target     Target    `json:"target"`
+	LocalPort  int       `json:"localPort"`
+	Pod        string    `json:"pod"`
+	RemotePort int       `json:"remotePort"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Retargets  int       `json:"retargets"`
+	StartedAt  time.Time `json:"startedAt"`
+	Uptime     string    `json:"uptime"`
+}
+
+// Manager runs the port forwards of the server, re-targeting them at a new
+// pod when the one backing them goes away. A forward whose target is deleted
+// stops, it's listed with its error until it's stopped explicitly.
+type Manager struct {
+	mux        sync.Mutex
+	clientPool *kubeclient.ClientPool
+	forwards   map[string]*forward
+	logger     *logrus.Entry
+}
+
+func NewManager(clientPool *kubeclient.ClientPool) *Manager {
+	return &Manager{
+		clientPool: clientPool,
+		forwards:   make(map[string]*forward),
+		logger:     logrus.WithField("module", "portforward"),
+	}
+}
+
+// Start forwards localPort, a random one when 0, to the target. It returns
+// once the first pod is forwarded.
+func (m *Manager) Start(ctx context.Context, target Target, localPort int) (Forward, error) {
+	if target.Kind != KindService && target.Kind != KindPod {
+		return Forward{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidTarget, target.Kind)
+	}
+	if target.Name == "" || target.Port == "" {
+		return Forward{}, fmt.Errorf("%w: name and port are required", ErrInvalidTarget)
+	}
+	kctx, err := m.clientPool.Context(target.Context)
+	if err != nil {
+		return Forward{}, err
+	}
+	client, err := kctx.Clientset()
+	if err != nil {
+		return Forward{}, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	f := &forward{
+		id:        uuid.NewString(),
+		target:    target,
+		kctx:      kctx,
+		client:    client,
+		localPort: localPort,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		retry:     defaultRetry,
+	}
+	f.forwardPod = f.portForward
+	f.logger = m.logger.WithField("forward", f.id).WithField("target", target)
+
+	// the first pod is forwarded synchronously so that errors are reported
+	pod, remotePort, err := f.pickPod(ctx)
+	if err != nil {
+		cancel()
+		return Forward{}, err
+	}
+	ready := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(f.done)
+		errs <- f.forwardPod(runCtx, pod, remotePort, ready)
+		f.run(runCtx)
+	}()
+	select {
+	case <-ready:
+	case err := <-errs:
+		cancel()
+		if err == nil {
+			err = errors.New("port forward stopped before being ready")
+		}
+		return Forward{}, err
+	case <-time.After(readyTimeout):
+		cancel()
+		return Forward{}, fmt.Errorf("port forward not ready after %s", readyTimeout)
+	}
+
+	m.mux.Lock()
+	m.forwards[f.id] = f
+	m.mux.Unlock()
+	f.logger.WithField("localPort", f.info().LocalPort).Info("Started port forward")
+	return f.info(), nil
+}
+
+// List returns the forwards, oldest first.
+func (m *Manager) List() []Forward {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	forwards := make([]Forward, 0, len(m.forwards))
+	for _, f := range m.forwards {
+		forwards = append(forwards, f.info())
+	}
+	sort.Slice(forwards, func(i, j int) bool {
+		return forwards[i].StartedAt.Before(forwards[j].StartedAt)
+	})
+	return forwards
+}
+
+// Stop stops a forward and waits for its local port to be released.
+func (m *Manager) Stop(id string) error {
+	m.mux.Lock()
+	f, found := m.forwards[id]
+	delete(m.forwards, id)
+	m.mux.Unlock()
+	if !found {
+		return ErrForwardNotFound
+	}
+	f.cancel()
+	<-f.done
+	f.logger.Info("Stopped port forward")
+	return nil
+}
+
+type forward struct {
+	id        string
+	target    Target
+	kctx      *kubeclient.Context
+	client    kubernetes.Interface
+	startedAt time.Time
+	cancel    context.CancelFunc
+	done      chan struct{}
+	logger    *logrus.Entry
+	retry     retryPolicy
+	// forwardPod forwards the local port to a pod until the pod goes away
+	forwardPod func(ctx context.Context, pod *corev1.Pod, remotePort int, ready chan struct{}) error
+
+	mux        sync.Mutex
+	localPort  int
+	pod        string
+	remotePort int
+	status     string
+	err        string
+	retargets  int
+}
+
+func (f *forward) info() Forward {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return Forward{
+		ID:         f.id,
+		Target:     f.target,
+		LocalPort:  f.localPort,
+		Pod:        f.pod,
+		RemotePort: f.remotePort,
+		Status:     f.status,
+		Error:      f.err,
+		Retargets:  f.retargets,
+		StartedAt:  f.startedAt,
+		Uptime:     time.Since(f.startedAt).Round(time.Second).String(),
+	}
+}
+
+// run re-targets the forward until it is stopped, or until its target has
+// been missing for longer than the retry policy allows.
+func (f *forward) run(ctx context.Context) {
+	backoff := f.retry.minBackoff
+	var missingSince time.Time
+	for ctx.Err() == nil {
+		f.setStatus(StatusReconnecting)
+		pod, remotePort, err := f.pickPod(ctx)
+		switch {
+		case err == nil:
+			missingSince = time.Time{}
+			f.mux.Lock()
+			f.retargets++
+			f.mux.Unlock()
+			started := time.Now()
+			err = f.forwardPod(ctx, pod, remotePort, make(chan struct{}))
+			if time.Since(started) > f.retry.maxBackoff {
+				backoff = f.retry.minBackoff
+			}
+		case apierrors.IsNotFound(err):
+			if missingSince.IsZero() {
+				missingSince = time.Now()
+			}
+			if time.Since(missingSince) >= f.retry.goneAfter {
+				f.logger.WithError(err).Warn("Port forward target is gone, stopping")
+				f.mux.Lock()
+				f.status = StatusStopped
+				f.err = err.Error()
+				f.mux.Unlock()
+				return
+			}
+		default:
+			missingSince = time.Time{}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		f.logger.WithError(err).WithField("backoff", backoff).Warn("Port forward lost its pod, re-targeting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > f.retry.maxBackoff {
+			backoff = f.retry.maxBackoff
+		}
+	}
+}
+
+// portForward forwards the local port to a pod until the pod goes away or the
+// forward is stopped.
+func (f *forward) portForward(ctx context.Context, pod *corev1.Pod, remotePort int, ready chan struct{}) error {
+	watcher, err := f.watchPod(ctx, pod)
+	if err != nil {
+		return err
+	}
+	defer func() { watcher.Stop() }()
+
+	transport, upgrader, err := spdy.RoundTripperFor(f.kctx.RESTConfig())
+	if err != nil {
+		return err
+	}
+	req := f.client.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	f.mux.Lock()
+	localPort := f.localPort
+	f.mux.Unlock()
+	stop := make(chan struct{})
+	forwarder, err := clientportforward.New(
+		dialer,
+		[]string{fmt.Sprintf("%d:%d", localPort, remotePort)},
+		stop,
+		ready,
+		io.Discard,
+		io.Discard,
+	)
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- forwarder.ForwardPorts()
+	}()
+
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-ready:
+			ports, err := forwarder.GetPorts()
+			if err == nil && len(ports) > 0 {
+				f.mux.Lock()
+				f.localPort = int(ports[0].Local)
+				f.pod = pod.Name
+				f.remotePort = remotePort
+				f.status = StatusActive
+				f.mux.Unlock()
+			}
+			// ready is closed, don't select it again
+			ready = nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				// the server ends watches once in a while, watch again
+				// unless the pod went away meanwhile
+				watcher.Stop()
+				live, err := f.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+				if err == nil && live.UID == pod.UID && podRunning(live) {
+					watcher, err = f.watchPod(ctx, live)
+					if err == nil {
+						continue
+					}
+				}
+			} else if !podGone(event) {
+				continue
+			}
+			close(stop)
+			<-errs
+			return fmt.Errorf("pod %s went away", pod.Name)
+		case <-ctx.Done():
+			close(stop)
+			<-errs
+			return nil
+		}
+	}
+}
+
+func (f *forward) watchPod(ctx context.Context, pod *corev1.Pod) (watch.Interface, error) {
+	return f.client.CoreV1().Pods(pod.Namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", pod.Name).String(),
+		ResourceVersion: pod.ResourceVersion,
+	})
+}
+
+func podGone(event watch.Event) bool {
+	if event.Type == watch.Deleted {
+		return true
+	}
+	pod, ok := event.Object.(*corev1.Pod)
+	return ok && !podRunning(pod)
+}
+
+func (f *forward) setStatus(status string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.status = status
+}
+
+// pickPod finds the pod to forward to and the container port matching the
+// target port.
+func (f *forward) pickPod(ctx context.Context) (*corev1.Pod, int, error) {
+	pods := f.client.CoreV1().Pods(f.target.Namespace)
+	if f.target.Kind == KindPod {
+		pod, err := pods.Get(ctx, f.target.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, 0, err
+		}
+		if !podRunning(pod) {
+			return nil, 0, fmt.Errorf("%w: pod %s isn't running", ErrNoPod, pod.Name)
+		}
+		port, err := containerPort(pod, intOrString(f.target.Port))
+		return pod, port, err
+	}
+
+	service, err := f.client.CoreV1().Services(f.target.Namespace).Get(ctx, f.target.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(service.Spec.Selector) == 0 {
+		return nil, 0, fmt.Errorf("%w: service %s has no selector", ErrInvalidTarget, service.Name)
+	}
+	targetPort, err := serviceTargetPort(service, f.target.Port)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := pods.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	pod := pickReadyPod(list.Items)
+	if pod == nil {
+		return nil, 0, fmt.Errorf("%w: no pod backs service %s", ErrNoPod, service.Name)
+	}
+	port, err := containerPort(pod, targetPort)
+	return pod, port, err
+}
+
+// serviceTargetPort maps a service port, given by name or number, to the
+// port of the pods.
+func serviceTargetPort(service *corev1.Service, port string) (intstr.IntOrString, error) {
+	for _, p := range service.Spec.Ports {
+		if p.Name != port && strconv.Itoa(int(p.Port)) != port {
+			continue
+		}
+		if p.TargetPort.Type == intstr.Int && p.TargetPort.IntVal == 0 {
+			return intstr.FromInt(int(p.Port)), nil
+		}
+		return p.TargetPort, nil
+	}
+	return intstr.IntOrString{}, fmt.Errorf("%w: service %s has no port %s", ErrInvalidTarget, service.Name, port)
+}
+
+// containerPort resolves a named port against the containers of the pod.
+func containerPort(pod *corev1.Pod, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.Int {
+		return port.IntValue(), nil
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == port.StrVal {
+				return int(p.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: pod %s has no port named %s", ErrInvalidTarget, pod.Name, port.StrVal)
+}
+
+func intOrString(port string) intstr.IntOrString {
+	if n, err := strconv.Atoi(port); err == nil {
+		return intstr.FromInt(n)
+	}
+	return intstr.FromString(port)
+}
+
+// pickReadyPod prefers ready pods, by name for a stable choice.
+func pickReadyPod(pods []corev1.Pod) *corev1.Pod {
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	var running *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if !podRunning(pod) {
+			continue
+		}
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				return pod
+			}
+		}
+		if running == nil {
+			running = pod
+		}
+	}
+	return running
+}
+
+func podRunning(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+}
@@ -0,0 +1,139 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestServiceTargetPort(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80, TargetPort: intstr.FromString("http")},
+			{Name: "metrics", Port: 9090, TargetPort: intstr.FromInt(9091)},
+			{Name: "grpc", Port: 50051},
+		}},
+	}
+
+	port, err := serviceTargetPort(service, "80")
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromString("http"), port)
+
+	port, err = serviceTargetPort(service, "metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromInt(9091), port)
+
+	port, err = serviceTargetPort(service, "grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromInt(50051), port)
+
+	_, err = serviceTargetPort(service, "443")
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestContainerPort(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "app", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+	}}}
+
+	port, err := containerPort(pod, intstr.FromString("http"))
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, port)
+
+	port, err = containerPort(pod, intstr.FromInt(3000))
+	assert.NoError(t, err)
+	assert.Equal(t, 3000, port)
+
+	_, err = containerPort(pod, intstr.FromString("grpc"))
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestPickReadyPod(t *testing.T) {
+	now := metav1.Now()
+	pod := func(name string, phase corev1.PodPhase, ready bool, deleted bool) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: corev1.PodStatus{Phase: phase}}
+		if ready {
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		if deleted {
+			p.DeletionTimestamp = &now
+		}
+		return p
+	}
+
+	picked := pickReadyPod([]corev1.Pod{
+		pod("web-d", corev1.PodRunning, true, false),
+		pod("web-a", corev1.PodRunning, true, true),
+		pod("web-b", corev1.PodRunning, false, false),
+		pod("web-c", corev1.PodRunning, true, false),
+	})
+	assert.Equal(t, "web-c", picked.Name)
+
+	picked = pickReadyPod([]corev1.Pod{
+		pod("web-b", corev1.PodRunning, false, false),
+		pod("web-a", corev1.PodPending, false, false),
+	})
+	assert.Equal(t, "web-b", picked.Name)
+
+	assert.Nil(t, pickReadyPod([]corev1.Pod{pod("web-a", corev1.PodFailed, false, false)}))
+}
+
+func runningPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, Labels: map[string]string{"app": "web"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestForwardRun(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "web"},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)}},
+		},
+	}
+	client := fake.NewSimpleClientset(service, runningPod("web-a"), runningPod("web-b"))
+
+	f := &forward{
+		target: Target{Namespace: "shop", Kind: KindService, Name: "web", Port: "http"},
+		client: client,
+		logger: logrus.NewEntry(logrus.New()),
+		retry:  retryPolicy{minBackoff: time.Millisecond, maxBackoff: time.Millisecond, goneAfter: 20 * time.Millisecond},
+	}
+	var forwarded []string
+	f.forwardPod = func(ctx context.Context, pod *corev1.Pod, remotePort int, ready chan struct{}) error {
+		forwarded = append(forwarded, fmt.Sprintf("%s:%d", pod.Name, remotePort))
+		// the pod goes away, the last one with its service
+		assert.NoError(t, client.CoreV1().Pods("shop").Delete(ctx, pod.Name, metav1.DeleteOptions{}))
+		if pod.Name == "web-b" {
+			assert.NoError(t, client.CoreV1().Services("shop").Delete(ctx, "web", metav1.DeleteOptions{}))
+		}
+		return fmt.Errorf("pod %s went away", pod.Name)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("forward didn't stop")
+	}
+
+	assert.Equal(t, []string{"web-a:8080", "web-b:8080"}, forwarded)
+	info := f.info()
+	assert.Equal(t, 2, info.Retargets)
+	assert.Equal(t, StatusStopped, info.Status)
+	assert.Equal(t, `services "web" not found`, info.Error)
+}
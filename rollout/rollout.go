@@ -0,0 +1,339 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pmezard/go-difflib/difflib"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	ResourceDeployments  = "deployments"
+	ResourceStatefulSets = "statefulsets"
+	ResourceDaemonSets   = "daemonsets"
+
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+	podTemplateHashLabel  = "pod-template-hash"
+)
+
+var (
+	ErrUnsupportedResource = errors.New("rollouts are only supported for deployments, statefulsets and daemonsets")
+	ErrNotSupported        = errors.New("not supported")
+	ErrPaused              = errors.New("deployment is paused, resume it first")
+	ErrRevisionNotFound    = errors.New("revision not found")
+)
+
+var (
+	replicaSetsResource         = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	controllerRevisionsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "controllerrevisions"}
+)
+
+// Revision is a pod template a workload rolled out, kept by the replica set
+// of a deployment, or the controller revision of a statefulset or daemonset.
+type Revision struct {
+	Revision    int64                  `json:"revision"`
+	Name        string                 `json:"name"`
+	ChangeCause string                 `json:"changeCause,omitempty"`
+	CreatedAt   metav1.Time            `json:"createdAt"`
+	Current     bool                   `json:"current"`
+	Template    map[string]interface{} `json:"template"`
+	// data is the patch of a controller revision
+	data map[string]interface{}
+}
+
+// Workload is a deployment, statefulset or daemonset of a namespace.
+type Workload struct {
+	client    dynamic.Interface
+	resource  string
+	namespace string
+	name      string
+}
+
+func NewWorkload(client dynamic.Interface, resource string, namespace string, name string) (*Workload, error) {
+	switch resource {
+	case ResourceDeployments, ResourceStatefulSets, ResourceDaemonSets:
+	default:
+		return nil, fmt.Errorf("%w, not %s", ErrUnsupportedResource, resource)
+	}
+	return &Workload{client: client, resource: resource, namespace: namespace, name: name}, nil
+}
+
+func (w *Workload) resourceInterface() dynamic.ResourceInterface {
+	return w.client.
+		Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: w.resource}).
+		Namespace(w.namespace)
+}
+
+func (w *Workload) Get(ctx context.Context) (*unstructured.Unstructured, error) {
+	return w.resourceInterface().Get(ctx, w.name, metav1.GetOptions{})
+}
+
+// Restart rolls out the pods again by stamping the pod template, the way
+// kubectl rollout restart does.
+func (w *Workload) Restart(ctx context.Context) (*unstructured.Unstructured, error) {
+	obj, err := w.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if paused, _, _ := unstructured.NestedBool(obj.Object, "spec", "paused"); paused {
+		return nil, ErrPaused
+	}
+	return w.mergePatch(ctx, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						restartedAtAnnotation: time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+}
+
+// Pause stops a deployment from rolling out changes of its pod template,
+// only deployments can be paused.
+func (w *Workload) Pause(ctx context.Context) (*unstructured.Unstructured, error) {
+	return w.setPaused(ctx, true)
+}
+
+func (w *Workload) Resume(ctx context.Context) (*unstructured.Unstructured, error) {
+	return w.setPaused(ctx, false)
+}
+
+func (w *Workload) setPaused(ctx context.Context, paused bool) (*unstructured.Unstructured, error) {
+	if w.resource != ResourceDeployments {
+		return nil, fmt.Errorf("%w: %s can't be paused", ErrNotSupported, w.resource)
+	}
+	return w.mergePatch(ctx, map[string]interface{}{
+		"spec": map[string]interface{}{"paused": paused},
+	})
+}
+
+func (w *Workload) mergePatch(ctx context.Context, patch map[string]interface{}) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	return w.resourceInterface().Patch(ctx, w.name, types.MergePatchType, data, metav1.PatchOptions{})
+}
+
+// History returns the revisions of the workload, oldest first. The latest
+// one is the current one.
+func (w *Workload) History(ctx context.Context) ([]Revision, error) {
+	obj, err := w.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return w.history(ctx, obj)
+}
+
+func (w *Workload) history(ctx context.Context, obj *unstructured.Unstructured) ([]Revision, error) {
+	selector, err := labelSelector(obj)
+	if err != nil {
+		return nil, err
+	}
+	gvr := controllerRevisionsResource
+	if w.resource == ResourceDeployments {
+		gvr = replicaSetsResource
+	}
+	list, err := w.client.Resource(gvr).Namespace(w.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(list.Items))
+	for i := range list.Items {
+		item := &list.Items[i]
+		if !metav1.IsControlledBy(item, obj) {
+			continue
+		}
+		var rev Revision
+		if w.resource == ResourceDeployments {
+			rev, err = replicaSetRevision(item)
+		} else {
+			rev, err = controllerRevision(item)
+		}
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	if len(revisions) > 0 {
+		revisions[len(revisions)-1].Current = true
+	}
+	return revisions, nil
+}
+
+func labelSelector(obj *unstructured.Unstructured) (string, error) {
+	raw, _, err := unstructured.NestedMap(obj.Object, "spec", "selector")
+	if err != nil {
+		return "", err
+	}
+	ls := metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &ls); err != nil {
+		return "", err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&ls)
+	if err != nil {
+		return "", err
+	}
+	return selector.String(), nil
+}
+
+func replicaSetRevision(rs *unstructured.Unstructured) (Revision, error) {
+	revision, err := strconv.ParseInt(rs.GetAnnotations()[revisionAnnotation], 10, 64)
+	if err != nil {
+		return Revision{}, fmt.Errorf("replica set %s has an invalid revision: %w", rs.GetName(), err)
+	}
+	template, _, err := unstructured.NestedMap(rs.Object, "spec", "template")
+	if err != nil {
+		return Revision{}, err
+	}
+	// the hash is added by the deployment controller, it isn't part of the
+	// template of the deployment
+	unstructured.RemoveNestedField(template, "metadata", "labels", podTemplateHashLabel)
+	return Revision{
+		Revision:    revision,
+		Name:        rs.GetName(),
+		ChangeCause: rs.GetAnnotations()[changeCauseAnnotation],
+		CreatedAt:   rs.GetCreationTimestamp(),
+		Template:    template,
+	}, nil
+}
+
+func controllerRevision(cr *unstructured.Unstructured) (Revision, error) {
+	revision, _, err := unstructured.NestedInt64(cr.Object, "revision")
+	if err != nil {
+		return Revision{}, err
+	}
+	data, _, err := unstructured.NestedMap(cr.Object, "data")
+	if err != nil {
+		return Revision{}, err
+	}
+	template, _, err := unstructured.NestedMap(data, "spec", "template")
+	if err != nil {
+		return Revision{}, err
+	}
+	delete(template, "$patch")
+	return Revision{
+		Revision:    revision,
+		Name:        cr.GetName(),
+		ChangeCause: cr.GetAnnotations()[changeCauseAnnotation],
+		CreatedAt:   cr.GetCreationTimestamp(),
+		Template:    template,
+		data:        data,
+	}, nil
+}
+
+// Undo rolls the workload back to a revision, 0 being the one before the
+// current one. Undoing to the current revision changes nothing.
+func (w *Workload) Undo(ctx context.Context, revision int64) (*unstructured.Unstructured, error) {
+	obj, err := w.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := w.history(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if revision == 0 {
+		revision, err = Previous(revisions, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	target, err := Find(revisions, revision)
+	if err != nil {
+		return nil, err
+	}
+	if target.Current {
+		return obj, nil
+	}
+
+	if w.resource != ResourceDeployments {
+		// controller revisions are the patch restoring the revision
+		data, err := json.Marshal(target.data)
+		if err != nil {
+			return nil, err
+		}
+		return w.resourceInterface().Patch(ctx, w.name, types.StrategicMergePatchType, data, metav1.PatchOptions{})
+	}
+	if paused, _, _ := unstructured.NestedBool(obj.Object, "spec", "paused"); paused {
+		return nil, ErrPaused
+	}
+	data, err := json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/spec/template", "value": target.Template},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.resourceInterface().Patch(ctx, w.name, types.JSONPatchType, data, metav1.PatchOptions{})
+}
+
+// Find returns a revision of the history, 0 being the current one.
+func Find(revisions []Revision, revision int64) (*Revision, error) {
+	for i := range revisions {
+		if revisions[i].Revision == revision || (revision == 0 && revisions[i].Current) {
+			return &revisions[i], nil
+		}
+	}
+	if revision == 0 {
+		return nil, fmt.Errorf("%w: the workload has no revision yet", ErrRevisionNotFound)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, revision)
+}
+
+// Previous returns the number of the revision before a revision, 0 being
+// the current one.
+func Previous(revisions []Revision, revision int64) (int64, error) {
+	rev, err := Find(revisions, revision)
+	if err != nil {
+		return 0, err
+	}
+	previous := int64(0)
+	for _, r := range revisions {
+		if r.Revision < rev.Revision && r.Revision > previous {
+			previous = r.Revision
+		}
+	}
+	if previous == 0 {
+		return 0, fmt.Errorf("%w: no revision before %d", ErrRevisionNotFound, rev.Revision)
+	}
+	return previous, nil
+}
+
+// Diff returns the unified diff of the pod templates of two revisions.
+func Diff(from, to *Revision) (string, error) {
+	fromYAML, err := yaml.Marshal(from.Template)
+	if err != nil {
+		return "", err
+	}
+	toYAML, err := yaml.Marshal(to.Template)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(fromYAML)),
+		B:        difflib.SplitLines(string(toYAML)),
+		FromFile: fmt.Sprintf("revision %d", from.Revision),
+		ToFile:   fmt.Sprintf("revision %d", to.Revision),
+		Context:  3,
+	})
+}
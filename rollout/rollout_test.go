@@ -0,0 +1,166 @@
+package rollout
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
+	"testing"
+)
+
+func object(kind string, generation int64, spec, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": "web", "generation": generation},
+		"spec":       spec,
+		"status":     status,
+	}}
+}
+
+func TestStatusOf_Deployment(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     *unstructured.Unstructured
+		done    bool
+		failed  bool
+		message string
+	}{
+		{
+			name:    "not observed",
+			obj:     object("Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"observedGeneration": int64(1)}),
+			message: "Waiting for deployment spec update to be observed...",
+		},
+		{
+			name: "updating",
+			obj: object("Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(4), "updatedReplicas": int64(1),
+			}),
+			message: `Waiting for deployment "web" rollout to finish: 1 out of 3 new replicas have been updated...`,
+		},
+		{
+			name: "terminating old replicas",
+			obj: object("Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(4), "updatedReplicas": int64(3),
+			}),
+			message: `Waiting for deployment "web" rollout to finish: 1 old replicas are pending termination...`,
+		},
+		{
+			name: "deadline exceeded",
+			obj: object("Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(4), "updatedReplicas": int64(1),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"},
+				},
+			}),
+			failed:  true,
+			message: `deployment "web" exceeded its progress deadline`,
+		},
+		{
+			name: "rolled out",
+			obj: object("Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(3), "availableReplicas": int64(3),
+			}),
+			done:    true,
+			message: `deployment "web" successfully rolled out`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := StatusOf(test.obj)
+			assert.NoError(t, err)
+			assert.Equal(t, test.done, status.Done)
+			assert.Equal(t, test.failed, status.Failed)
+			assert.Equal(t, test.message, status.Message)
+		})
+	}
+}
+
+func TestStatusOf_StatefulSetAndDaemonSet(t *testing.T) {
+	sts := object("StatefulSet", 1, map[string]interface{}{
+		"replicas":       int64(3),
+		"updateStrategy": map[string]interface{}{"type": "RollingUpdate", "rollingUpdate": map[string]interface{}{"partition": int64(2)}},
+	}, map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(3), "updatedReplicas": int64(1)})
+	status, err := StatusOf(sts)
+	assert.NoError(t, err)
+	assert.True(t, status.Done)
+	assert.Equal(t, "partitioned roll out complete: 1 new pods have been updated...", status.Message)
+
+	onDelete := object("StatefulSet", 1, map[string]interface{}{
+		"updateStrategy": map[string]interface{}{"type": "OnDelete"},
+	}, map[string]interface{}{})
+	_, err = StatusOf(onDelete)
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	ds := object("DaemonSet", 1, map[string]interface{}{}, map[string]interface{}{
+		"observedGeneration": int64(1), "desiredNumberScheduled": int64(4), "updatedNumberScheduled": int64(4), "numberAvailable": int64(2),
+	})
+	status, err = StatusOf(ds)
+	assert.NoError(t, err)
+	assert.False(t, status.Done)
+	assert.Equal(t, `Waiting for daemon set "web" rollout to finish: 2 of 4 updated pods are available...`, status.Message)
+}
+
+func TestRevisions(t *testing.T) {
+	rs := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "web-5d4f8",
+			"annotations": map[string]interface{}{revisionAnnotation: "3", changeCauseAnnotation: "bump image"},
+		},
+		"spec": map[string]interface{}{"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web", podTemplateHashLabel: "5d4f8"}},
+		}},
+	}}
+	rev, err := replicaSetRevision(rs)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rev.Revision)
+	assert.Equal(t, "bump image", rev.ChangeCause)
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+	}, rev.Template)
+
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "db-6c9b7"},
+		"revision": int64(2),
+		"data": map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{
+			"$patch": "replace",
+			"spec":   map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "db", "image": "postgres:15"}}},
+		}}},
+	}}
+	rev, err = controllerRevision(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rev.Revision)
+	assert.NotContains(t, rev.Template, "$patch")
+	assert.Contains(t, rev.data, "spec")
+}
+
+func TestFindPreviousAndDiff(t *testing.T) {
+	image := func(image string) map[string]interface{} {
+		return map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": image}},
+		}}
+	}
+	revisions := []Revision{
+		{Revision: 1, Template: image("web:1")},
+		{Revision: 3, Template: image("web:3")},
+		{Revision: 4, Template: image("web:4"), Current: true},
+	}
+
+	current, err := Find(revisions, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), current.Revision)
+
+	previous, err := Previous(revisions, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), previous)
+
+	_, err = Previous(revisions, 1)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	_, err = Find(revisions, 2)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+	diff, err := Diff(&revisions[0], current)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(diff, "--- revision 1\n+++ revision 4\n"))
+	assert.Contains(t, diff, "-  - image: web:1\n")
+	assert.Contains(t, diff, "+  - image: web:4\n")
+}
@@ -0,0 +1,223 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+const (
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	strategyRollingUpdate          = "RollingUpdate"
+)
+
+// Status is the progress of the rollout of a workload. Failed is only ever
+// set for deployments, which have a progress deadline.
+type Status struct {
+	Done      bool   `json:"done"`
+	Failed    bool   `json:"failed"`
+	Message   string `json:"message"`
+	Replicas  int64  `json:"replicas"`
+	Updated   int64  `json:"updated"`
+	Ready     int64  `json:"ready"`
+	Available int64  `json:"available"`
+}
+
+// StatusOf tells how far the rollout of a workload is, following the rules
+// of kubectl rollout status.
+func StatusOf(obj *unstructured.Unstructured) (Status, error) {
+	switch obj.GetKind() {
+	case "Deployment":
+		return deploymentStatus(obj), nil
+	case "StatefulSet":
+		return statefulSetStatus(obj)
+	case "DaemonSet":
+		return daemonSetStatus(obj)
+	}
+	return Status{}, fmt.Errorf("%w, not %s", ErrUnsupportedResource, obj.GetKind())
+}
+
+func deploymentStatus(obj *unstructured.Unstructured) Status {
+	s := Status{
+		Replicas:  nestedInt(obj, "status", "replicas"),
+		Updated:   nestedInt(obj, "status", "updatedReplicas"),
+		Ready:     nestedInt(obj, "status", "readyReplicas"),
+		Available: nestedInt(obj, "status", "availableReplicas"),
+	}
+	if !observed(obj) {
+		s.Message = "Waiting for deployment spec update to be observed..."
+		return s
+	}
+	if progressDeadlineExceeded(obj) {
+		s.Failed = true
+		s.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", obj.GetName())
+		return s
+	}
+	replicas := int64(1)
+	if r, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		replicas = r
+	}
+	switch {
+	case s.Updated < replicas:
+		s.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", obj.GetName(), s.Updated, replicas)
+	case s.Replicas > s.Updated:
+		s.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", obj.GetName(), s.Replicas-s.Updated)
+	case s.Available < s.Updated:
+		s.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", obj.GetName(), s.Available, s.Updated)
+	default:
+		s.Done = true
+		s.Message = fmt.Sprintf("deployment %q successfully rolled out", obj.GetName())
+	}
+	return s
+}
+
+func statefulSetStatus(obj *unstructured.Unstructured) (Status, error) {
+	if err := rollingUpdate(obj); err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		Replicas:  nestedInt(obj, "status", "replicas"),
+		Updated:   nestedInt(obj, "status", "updatedReplicas"),
+		Ready:     nestedInt(obj, "status", "readyReplicas"),
+		Available: nestedInt(obj, "status", "availableReplicas"),
+	}
+	if nestedInt(obj, "status", "observedGeneration") == 0 || !observed(obj) {
+		s.Message = "Waiting for statefulset spec update to be observed..."
+		return s, nil
+	}
+	replicas, hasReplicas, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if hasReplicas && s.Ready < replicas {
+		s.Message = fmt.Sprintf("Waiting for %d pods to be ready...", replicas-s.Ready)
+		return s, nil
+	}
+	if partition, found, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition"); found && hasReplicas {
+		if s.Updated < replicas-partition {
+			s.Message = fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", s.Updated, replicas-partition)
+			return s, nil
+		}
+		s.Done = true
+		s.Message = fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", s.Updated)
+		return s, nil
+	}
+	updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	if updateRevision != currentRevision {
+		s.Message = fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...", s.Updated, updateRevision)
+		return s, nil
+	}
+	s.Done = true
+	s.Message = fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...", s.Ready, currentRevision)
+	return s, nil
+}
+
+func daemonSetStatus(obj *unstructured.Unstructured) (Status, error) {
+	if err := rollingUpdate(obj); err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		Replicas:  nestedInt(obj, "status", "desiredNumberScheduled"),
+		Updated:   nestedInt(obj, "status", "updatedNumberScheduled"),
+		Ready:     nestedInt(obj, "status", "numberReady"),
+		Available: nestedInt(obj, "status", "numberAvailable"),
+	}
+	if !observed(obj) {
+		s.Message = "Waiting for daemon set spec update to be observed..."
+		return s, nil
+	}
+	switch {
+	case s.Updated < s.Replicas:
+		s.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated...", obj.GetName(), s.Updated, s.Replicas)
+	case s.Available < s.Replicas:
+		s.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available...", obj.GetName(), s.Available, s.Replicas)
+	default:
+		s.Done = true
+		s.Message = fmt.Sprintf("daemon set %q successfully rolled out", obj.GetName())
+	}
+	return s, nil
+}
+
+// rollingUpdate rejects the OnDelete strategy, which has no rollout to
+// follow.
+func rollingUpdate(obj *unstructured.Unstructured) error {
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy != "" && strategy != strategyRollingUpdate {
+		return fmt.Errorf("%w: rollout status is only available for the %s strategy, %s has %s",
+			ErrNotSupported, strategyRollingUpdate, obj.GetName(), strategy)
+	}
+	return nil
+}
+
+func observed(obj *unstructured.Unstructured) bool {
+	return obj.GetGeneration() <= nestedInt(obj, "status", "observedGeneration")
+}
+
+func progressDeadlineExceeded(obj *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Progressing" && condition["reason"] == reasonProgressDeadlineExceeded {
+			return true
+		}
+	}
+	return false
+}
+
+func nestedInt(obj *unstructured.Unstructured, fields ...string) int64 {
+	i, _, _ := unstructured.NestedInt64(obj.Object, fields...)
+	return i
+}
+
+// WatchStatus reports the status of the rollout on every change of the
+// workload until it's done or failed. It returns the error of ctx when ctx
+// ends first.
+func (w *Workload) WatchStatus(ctx context.Context, report func(Status)) error {
+	// the workload has to exist, the watch would wait for it otherwise
+	if _, err := w.Get(ctx); err != nil {
+		return err
+	}
+	resource := w.resourceInterface()
+	selector := fields.OneTermEqualSelector("metadata.name", w.name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return resource.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return resource.Watch(ctx, options)
+		},
+	}
+	_, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, nil, func(e watch.Event) (bool, error) {
+		switch e.Type {
+		case watch.Deleted:
+			return false, fmt.Errorf("%s %s has been deleted", w.resource, w.name)
+		case watch.Added, watch.Modified:
+			obj, ok := e.Object.(*unstructured.Unstructured)
+			if !ok {
+				return false, nil
+			}
+			status, err := StatusOf(obj)
+			if err != nil {
+				return false, err
+			}
+			report(status)
+			return status.Done || status.Failed, nil
+		}
+		return false, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}